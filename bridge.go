@@ -0,0 +1,185 @@
+package panlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelWriter returns a writer logging every line written to it as an entry
+// at level. Unlike logrus' WriterLevel it needs no goroutine or Close.
+func (l *Logger) LevelWriter(level logrus.Level) io.Writer {
+	return &levelWriter{entry: logrus.NewEntry(&l.Logger), level: level}
+}
+
+// levelWriter logs the lines written to it
+type levelWriter struct {
+	entry *logrus.Entry
+	level logrus.Level
+}
+
+// Write implements io.Writer
+func (w *levelWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\r\n"), []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) > 0 {
+			w.entry.Log(w.level, string(line))
+		}
+	}
+	return len(p), nil
+}
+
+// RedirectStdLog sends the output of the standard library's default logger
+// to the logger at level and returns a function restoring the previous
+// output. Its flags and prefix are cleared since entries have their own
+// timestamp.
+func (l *Logger) RedirectStdLog(level logrus.Level) (restore func()) {
+	writer, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+
+	log.SetOutput(l.LevelWriter(level))
+	log.SetFlags(0)
+	log.SetPrefix("")
+
+	return func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}
+}
+
+// StdLogger returns a standard library logger writing to the logger at
+// level, for libraries that accept a *log.Logger
+func (l *Logger) StdLogger(level logrus.Level) *log.Logger {
+	return log.New(l.LevelWriter(level), "", 0)
+}
+
+// PrintfLogger adapts the logger to interfaces with a single Printf method
+type PrintfLogger struct {
+	entry *logrus.Entry
+	level logrus.Level
+}
+
+// PrintfLogger returns an adapter logging Printf calls at level
+func (l *Logger) PrintfLogger(level logrus.Level) *PrintfLogger {
+	return &PrintfLogger{entry: logrus.NewEntry(&l.Logger), level: level}
+}
+
+// Printf logs a formatted message
+func (p *PrintfLogger) Printf(format string, args ...interface{}) {
+	p.entry.Logf(p.level, format, args...)
+}
+
+// GRPCLogger implements the grpclog.LoggerV2 interface, so that it can be
+// passed to grpclog.SetLoggerV2
+type GRPCLogger struct {
+	entry     *logrus.Entry
+	verbosity int
+}
+
+// GRPCLogger returns a grpclog.LoggerV2 logging at most the given verbosity
+// level; gRPC's own logs use the "grpc" module
+func (l *Logger) GRPCLogger(verbosity int) *GRPCLogger {
+	return &GRPCLogger{entry: l.Module("grpc").Entry, verbosity: verbosity}
+}
+
+// Info logs at info level
+func (g *GRPCLogger) Info(args ...interface{}) { g.entry.Info(args...) }
+
+// Infoln logs at info level
+func (g *GRPCLogger) Infoln(args ...interface{}) { g.entry.Infoln(args...) }
+
+// Infof logs at info level
+func (g *GRPCLogger) Infof(format string, args ...interface{}) { g.entry.Infof(format, args...) }
+
+// Warning logs at warn level
+func (g *GRPCLogger) Warning(args ...interface{}) { g.entry.Warn(args...) }
+
+// Warningln logs at warn level
+func (g *GRPCLogger) Warningln(args ...interface{}) { g.entry.Warnln(args...) }
+
+// Warningf logs at warn level
+func (g *GRPCLogger) Warningf(format string, args ...interface{}) { g.entry.Warnf(format, args...) }
+
+// Error logs at error level
+func (g *GRPCLogger) Error(args ...interface{}) { g.entry.Error(args...) }
+
+// Errorln logs at error level
+func (g *GRPCLogger) Errorln(args ...interface{}) { g.entry.Errorln(args...) }
+
+// Errorf logs at error level
+func (g *GRPCLogger) Errorf(format string, args ...interface{}) { g.entry.Errorf(format, args...) }
+
+// Fatal logs at fatal level and exits
+func (g *GRPCLogger) Fatal(args ...interface{}) { g.entry.Fatal(args...) }
+
+// Fatalln logs at fatal level and exits
+func (g *GRPCLogger) Fatalln(args ...interface{}) { g.entry.Fatalln(args...) }
+
+// Fatalf logs at fatal level and exits
+func (g *GRPCLogger) Fatalf(format string, args ...interface{}) { g.entry.Fatalf(format, args...) }
+
+// V reports whether verbosity level l is logged
+func (g *GRPCLogger) V(l int) bool {
+	return l <= g.verbosity
+}
+
+// LeveledLogger implements the key/value logger interface of
+// hashicorp/go-retryablehttp and similar libraries. The key/value pairs
+// become entry fields.
+type LeveledLogger struct {
+	entry *logrus.Entry
+}
+
+// LeveledLogger returns a key/value logger adapter
+func (l *Logger) LeveledLogger() *LeveledLogger {
+	return &LeveledLogger{entry: logrus.NewEntry(&l.Logger)}
+}
+
+// Error logs at error level
+func (k *LeveledLogger) Error(msg string, keysAndValues ...interface{}) {
+	k.log(logrus.ErrorLevel, msg, keysAndValues)
+}
+
+// Warn logs at warn level
+func (k *LeveledLogger) Warn(msg string, keysAndValues ...interface{}) {
+	k.log(logrus.WarnLevel, msg, keysAndValues)
+}
+
+// Info logs at info level
+func (k *LeveledLogger) Info(msg string, keysAndValues ...interface{}) {
+	k.log(logrus.InfoLevel, msg, keysAndValues)
+}
+
+// Debug logs at debug level
+func (k *LeveledLogger) Debug(msg string, keysAndValues ...interface{}) {
+	k.log(logrus.DebugLevel, msg, keysAndValues)
+}
+
+// log logs msg with the key/value pairs as fields
+func (k *LeveledLogger) log(level logrus.Level, msg string, keysAndValues []interface{}) {
+	if !k.entry.Logger.IsLevelEnabled(level) {
+		return
+	}
+	k.entry.WithFields(keyValueFields(keysAndValues)).Log(level, msg)
+}
+
+// keyValueFields converts alternating keys and values to fields. A trailing
+// key without value is kept under "extra".
+func keyValueFields(keysAndValues []interface{}) logrus.Fields {
+	fields := make(logrus.Fields, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			fields["extra"] = keysAndValues[i]
+			break
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		fields[key] = keysAndValues[i+1]
+	}
+	return fields
+}
@@ -0,0 +1,135 @@
+package panlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// grpcLoggerV2 mirrors grpclog.LoggerV2
+type grpcLoggerV2 interface {
+	Info(args ...interface{})
+	Infoln(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warning(args ...interface{})
+	Warningln(args ...interface{})
+	Warningf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorln(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatal(args ...interface{})
+	Fatalln(args ...interface{})
+	Fatalf(format string, args ...interface{})
+	V(l int) bool
+}
+
+// retryableLeveledLogger mirrors retryablehttp.LeveledLogger
+type retryableLeveledLogger interface {
+	Error(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Debug(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+}
+
+var (
+	_ grpcLoggerV2                                = (*GRPCLogger)(nil)
+	_ retryableLeveledLogger                      = (*LeveledLogger)(nil)
+	_ interface{ Printf(string, ...interface{}) } = (*PrintfLogger)(nil)
+)
+
+// newBufferLogger returns a logger with config writing JSON entries to a
+// buffer. LogLevel defaults to debug.
+func newBufferLogger(t *testing.T, config LoggerConfig) (*Logger, *bytes.Buffer) {
+	var out bytes.Buffer
+	if config.LogLevel == "" {
+		config.LogLevel = "debug"
+	}
+	config.Format = "json"
+	config.Sinks = append(config.Sinks, SinkConfig{Name: "buffer", Sink: NewWriterSink(&out)})
+
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	return logger, &out
+}
+
+// bufferEntries parses the JSON entries written to a buffer
+func bufferEntries(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("Failed to parse entry %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{})
+
+	previous := log.Writer()
+	restore := logger.RedirectStdLog(logrus.WarnLevel)
+	log.Printf("legacy %d", 1)
+	log.Print("first\nsecond")
+	restore()
+
+	logger.StdLogger(logrus.ErrorLevel).Println("from std logger")
+
+	entries := bufferEntries(t, out)
+	expected := []struct{ msg, level string }{
+		{"legacy 1", "warning"},
+		{"first", "warning"},
+		{"second", "warning"},
+		{"from std logger", "error"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %v", len(expected), entries)
+	}
+	for i, e := range expected {
+		if entries[i]["msg"] != e.msg || entries[i]["level"] != e.level {
+			t.Errorf("Entry %d: expected %s %q, got %v", i, e.level, e.msg, entries[i])
+		}
+	}
+	if log.Writer() != previous {
+		t.Errorf("Expected restore to reset the std log output")
+	}
+}
+
+func TestLoggerAdapters(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{})
+
+	logger.PrintfLogger(logrus.DebugLevel).Printf("printf %s", "adapter")
+
+	grpcLogger := logger.GRPCLogger(2)
+	grpcLogger.Warningf("grpc %s", "warning")
+	if !grpcLogger.V(2) || grpcLogger.V(3) {
+		t.Errorf("Expected verbosity 2")
+	}
+
+	logger.LeveledLogger().Info("request retried", "attempt", 2, "url", "http://example.com", "dangling")
+
+	entries := bufferEntries(t, out)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %v", entries)
+	}
+	if entries[0]["msg"] != "printf adapter" || entries[0]["level"] != "debug" {
+		t.Errorf("Unexpected printf entry %v", entries[0])
+	}
+	if entries[1]["msg"] != "grpc warning" || entries[1]["level"] != "warning" || entries[1][ModuleField] != "grpc" {
+		t.Errorf("Unexpected grpc entry %v", entries[1])
+	}
+	if entries[2]["msg"] != "request retried" || entries[2]["attempt"] != float64(2) ||
+		entries[2]["url"] != "http://example.com" || entries[2]["extra"] != "dangling" {
+		t.Errorf("Unexpected leveled entry %v", entries[2])
+	}
+}
@@ -0,0 +1,770 @@
+package panlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigSources describes where a LoggerConfig is assembled from. Sources are
+// applied in order of increasing precedence: Base, File, the environment and
+// finally Overrides. Only values that are present in a source replace the
+// values of the sources below it.
+type ConfigSources struct {
+	Base      LoggerConfig      // Starting configuration
+	File      string            // Path to a YAML, JSON or TOML config file (optional)
+	EnvPrefix string            // Prefix of environment variables, e.g. "APP" for APP_LOG_LEVEL (optional)
+	Overrides map[string]string // Values keyed like the config file, e.g. from command-line flags
+}
+
+// LoadConfig reads a logger configuration from a YAML, JSON or TOML file.
+// The format is chosen from the file extension.
+func LoadConfig(path string) (LoggerConfig, error) {
+	return ConfigSources{File: path}.Load()
+}
+
+// ConfigFromEnv reads a logger configuration from environment variables named
+// after the config file keys, e.g. APP_LOG_LEVEL or APP_MAX_SIZE for prefix "APP"
+func ConfigFromEnv(prefix string) (LoggerConfig, error) {
+	return ConfigSources{EnvPrefix: prefix}.Load()
+}
+
+// Load merges all configured sources into a single LoggerConfig
+func (s ConfigSources) Load() (LoggerConfig, error) {
+	var merged fileConfig
+
+	if s.File != "" {
+		fc, err := readConfigFile(s.File)
+		if err != nil {
+			return LoggerConfig{}, err
+		}
+		merged.merge(fc)
+	}
+
+	if s.EnvPrefix != "" {
+		fc, err := envConfig(s.EnvPrefix)
+		if err != nil {
+			return LoggerConfig{}, err
+		}
+		merged.merge(fc)
+	}
+
+	if len(s.Overrides) > 0 {
+		var fc fileConfig
+		keys := make([]string, 0, len(s.Overrides))
+		for key := range s.Overrides {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := fc.set(key, s.Overrides[key]); err != nil {
+				return LoggerConfig{}, fmt.Errorf("override %s: %w", key, err)
+			}
+		}
+		merged.merge(fc)
+	}
+
+	config := s.Base
+	merged.apply(&config)
+
+	if err := config.Validate(); err != nil {
+		return LoggerConfig{}, err
+	}
+
+	return config, nil
+}
+
+// fileConfig is the on-disk representation of LoggerConfig. Every field is
+// optional so that sources can be layered on top of each other.
+type fileConfig struct {
+	LogLevel      *string        `json:"log_level" yaml:"log_level" toml:"log_level"`
+	LogFile       *string        `json:"log_file" yaml:"log_file" toml:"log_file"`
+	MaxSize       *sizeValue     `json:"max_size" yaml:"max_size" toml:"max_size"`
+	MaxAge        *durationValue `json:"max_age" yaml:"max_age" toml:"max_age"`
+	MaxBackups    *backupsValue  `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	Compress      *bool          `json:"compress" yaml:"compress" toml:"compress"`
+	RotateDaily   *bool          `json:"rotate_daily" yaml:"rotate_daily" toml:"rotate_daily"`
+	HashChain     *bool          `json:"hash_chain" yaml:"hash_chain" toml:"hash_chain"`
+	ManifestKey   *string        `json:"manifest_key_file" yaml:"manifest_key_file" toml:"manifest_key_file"`
+	Format        *string        `json:"format" yaml:"format" toml:"format"`
+	JSONFormat    *bool          `json:"json_format" yaml:"json_format" toml:"json_format"`
+	ConsoleOutput *bool          `json:"console_output" yaml:"console_output" toml:"console_output"`
+	CountField    *string        `json:"count_field" yaml:"count_field" toml:"count_field"`
+	FileLevel     *string        `json:"file_level" yaml:"file_level" toml:"file_level"`
+	FileFormat    *string        `json:"file_format" yaml:"file_format" toml:"file_format"`
+	ConsoleLevel  *string        `json:"console_level" yaml:"console_level" toml:"console_level"`
+	ConsoleFormat *string        `json:"console_format" yaml:"console_format" toml:"console_format"`
+
+	ServiceName    *string       `json:"service_name" yaml:"service_name" toml:"service_name"`
+	ServiceVersion *string       `json:"service_version" yaml:"service_version" toml:"service_version"`
+	LogfmtKeys     *stringsValue `json:"logfmt_keys" yaml:"logfmt_keys" toml:"logfmt_keys"`
+
+	ModuleLevels *moduleLevelsValue              `json:"module_levels" yaml:"module_levels" toml:"module_levels"`
+	Sinks        *[]sinkFileConfig               `json:"sinks" yaml:"sinks" toml:"sinks"`
+	ErrorLog     *errorLogFileConfig             `json:"error_log" yaml:"error_log" toml:"error_log"`
+	Redaction    *redactionFileConfig            `json:"redaction" yaml:"redaction" toml:"redaction"`
+	Sampling     *samplingFileConfig             `json:"sampling" yaml:"sampling" toml:"sampling"`
+	DedupWindow  *durationValue                  `json:"dedup_window" yaml:"dedup_window" toml:"dedup_window"`
+	RateLimits   *map[string]rateLimitFileConfig `json:"rate_limits" yaml:"rate_limits" toml:"rate_limits"`
+}
+
+// rotatorFileConfig holds the file and rotation settings of sinks and the
+// error log in config files
+type rotatorFileConfig struct {
+	Path        string        `json:"path" yaml:"path" toml:"path"`
+	MaxSize     sizeValue     `json:"max_size" yaml:"max_size" toml:"max_size"`
+	MaxAge      durationValue `json:"max_age" yaml:"max_age" toml:"max_age"`
+	MaxBackups  backupsValue  `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	Compress    bool          `json:"compress" yaml:"compress" toml:"compress"`
+	RotateDaily bool          `json:"rotate_daily" yaml:"rotate_daily" toml:"rotate_daily"`
+	HashChain   bool          `json:"hash_chain" yaml:"hash_chain" toml:"hash_chain"`
+	ManifestKey string        `json:"manifest_key_file" yaml:"manifest_key_file" toml:"manifest_key_file"`
+}
+
+// rotatorConfig converts the on-disk representation to a LogRotatorConfig
+func (rc rotatorFileConfig) rotatorConfig() LogRotatorConfig {
+	return LogRotatorConfig{
+		FilePath:    rc.Path,
+		MaxSize:     int64(rc.MaxSize),
+		MaxAge:      time.Duration(rc.MaxAge),
+		MaxBackups:  int(rc.MaxBackups),
+		Compress:    rc.Compress,
+		RotateDaily: rc.RotateDaily,
+		HashChain:   rc.HashChain,
+
+		ManifestKeyFile: rc.ManifestKey,
+	}
+}
+
+// sinkFileConfig is the on-disk representation of SinkConfig. The file
+// settings of the file type are flattened into the sink.
+type sinkFileConfig struct {
+	Name              string            `json:"name" yaml:"name" toml:"name"`
+	Type              string            `json:"type" yaml:"type" toml:"type"`
+	Level             string            `json:"level" yaml:"level" toml:"level"`
+	Format            string            `json:"format" yaml:"format" toml:"format"`
+	Options           map[string]string `json:"options" yaml:"options" toml:"options"`
+	QueueSize         int               `json:"queue_size" yaml:"queue_size" toml:"queue_size"`
+	rotatorFileConfig `yaml:",inline"`
+}
+
+// sinkConfig converts the on-disk representation to a SinkConfig
+func (sc sinkFileConfig) sinkConfig() SinkConfig {
+	return SinkConfig{
+		Name:      sc.Name,
+		Type:      sc.Type,
+		Level:     sc.Level,
+		Format:    sc.Format,
+		File:      sc.rotatorConfig(),
+		Options:   sc.Options,
+		QueueSize: sc.QueueSize,
+	}
+}
+
+// errorLogFileConfig is the on-disk representation of ErrorLogConfig
+type errorLogFileConfig struct {
+	Enabled           bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Level             string `json:"level" yaml:"level" toml:"level"`
+	rotatorFileConfig `yaml:",inline"`
+}
+
+// redactionFileConfig is the on-disk representation of RedactionConfig
+type redactionFileConfig struct {
+	Rules []redactionRuleFileConfig `json:"rules" yaml:"rules" toml:"rules"`
+	Mask  string                    `json:"mask" yaml:"mask" toml:"mask"`
+	Salt  string                    `json:"salt" yaml:"salt" toml:"salt"`
+}
+
+// redactionRuleFileConfig is the on-disk representation of RedactionRule
+type redactionRuleFileConfig struct {
+	Field   string `json:"field" yaml:"field" toml:"field"`
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+	Builtin string `json:"builtin" yaml:"builtin" toml:"builtin"`
+	Action  string `json:"action" yaml:"action" toml:"action"`
+}
+
+// redactionConfig converts the on-disk representation to a RedactionConfig
+func (rc redactionFileConfig) redactionConfig() RedactionConfig {
+	config := RedactionConfig{Mask: rc.Mask, Salt: rc.Salt}
+	for _, rule := range rc.Rules {
+		config.Rules = append(config.Rules, RedactionRule(rule))
+	}
+	return config
+}
+
+// samplingFileConfig is the on-disk representation of SamplingConfig
+type samplingFileConfig struct {
+	Interval   durationValue               `json:"interval" yaml:"interval" toml:"interval"`
+	First      int                         `json:"first" yaml:"first" toml:"first"`
+	Thereafter int                         `json:"thereafter" yaml:"thereafter" toml:"thereafter"`
+	Levels     map[string]samplingRateFile `json:"levels" yaml:"levels" toml:"levels"`
+}
+
+// samplingRateFile is the on-disk representation of SamplingRate
+type samplingRateFile struct {
+	First      int `json:"first" yaml:"first" toml:"first"`
+	Thereafter int `json:"thereafter" yaml:"thereafter" toml:"thereafter"`
+}
+
+// samplingConfig converts the on-disk representation to a SamplingConfig
+func (sc samplingFileConfig) samplingConfig() SamplingConfig {
+	config := SamplingConfig{
+		Interval:   time.Duration(sc.Interval),
+		First:      sc.First,
+		Thereafter: sc.Thereafter,
+	}
+	if len(sc.Levels) > 0 {
+		config.Levels = make(map[string]SamplingRate, len(sc.Levels))
+		for level, rate := range sc.Levels {
+			config.Levels[level] = SamplingRate(rate)
+		}
+	}
+	return config
+}
+
+// rateLimitFileConfig is the on-disk representation of RateLimit
+type rateLimitFileConfig struct {
+	Rate  float64 `json:"rate" yaml:"rate" toml:"rate"`
+	Burst int     `json:"burst" yaml:"burst" toml:"burst"`
+}
+
+// readConfigFile decodes a config file, rejecting unknown keys
+func readConfigFile(path string) (fileConfig, error) {
+	var fc fileConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fc, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+			return fc, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&fc); err != nil {
+			return fc, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &fc)
+		if err != nil {
+			return fc, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fc, fmt.Errorf("failed to parse %s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return fc, fmt.Errorf("unsupported config file extension %q (want .yaml, .yml, .json or .toml)", ext)
+	}
+
+	return fc, nil
+}
+
+// envConfig reads every config key from the environment
+func envConfig(prefix string) (fileConfig, error) {
+	var fc fileConfig
+
+	prefix = strings.ToUpper(prefix)
+	if !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	for _, key := range configKeys() {
+		name := prefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := fc.set(key, value); err != nil {
+			return fc, fmt.Errorf("environment variable %s: %w", name, err)
+		}
+	}
+
+	return fc, nil
+}
+
+// configKeys returns the config file keys of all fileConfig fields
+func configKeys() []string {
+	t := reflect.TypeOf(fileConfig{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("json"))
+	}
+	return keys
+}
+
+// set parses a string value for the field identified by its config key
+func (fc *fileConfig) set(key, value string) error {
+	v := reflect.ValueOf(fc).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("json") != key {
+			continue
+		}
+
+		field := v.Field(i)
+		ptr := reflect.New(field.Type().Elem())
+		if err := parseConfigValue(ptr.Interface(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	return fmt.Errorf("unknown config key %q", key)
+}
+
+// parseConfigValue parses value into the variable dst points to
+func parseConfigValue(dst interface{}, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch dst := dst.(type) {
+	case *string:
+		*dst = value
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*dst = b
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*dst = n
+	case interface{ UnmarshalText([]byte) error }:
+		return dst.UnmarshalText([]byte(value))
+	default:
+		return fmt.Errorf("cannot be set from a string")
+	}
+
+	return nil
+}
+
+// merge copies every field that is set in other into fc
+func (fc *fileConfig) merge(other fileConfig) {
+	dst := reflect.ValueOf(fc).Elem()
+	src := reflect.ValueOf(other)
+
+	for i := 0; i < src.NumField(); i++ {
+		if !src.Field(i).IsNil() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// apply copies every field that is set in fc into config
+func (fc *fileConfig) apply(config *LoggerConfig) {
+	if fc.LogLevel != nil {
+		config.LogLevel = *fc.LogLevel
+	}
+	if fc.LogFile != nil {
+		config.LogFile = *fc.LogFile
+	}
+	if fc.MaxSize != nil {
+		config.MaxSize = int64(*fc.MaxSize)
+	}
+	if fc.MaxAge != nil {
+		config.MaxAge = time.Duration(*fc.MaxAge)
+	}
+	if fc.MaxBackups != nil {
+		config.MaxBackups = int(*fc.MaxBackups)
+	}
+	if fc.Compress != nil {
+		config.Compress = *fc.Compress
+	}
+	if fc.RotateDaily != nil {
+		config.RotateDaily = *fc.RotateDaily
+	}
+	if fc.HashChain != nil {
+		config.HashChain = *fc.HashChain
+	}
+	if fc.ManifestKey != nil {
+		config.ManifestKeyFile = *fc.ManifestKey
+	}
+	if fc.Format != nil {
+		config.Format = *fc.Format
+	}
+	if fc.JSONFormat != nil {
+		config.JSONFormat = *fc.JSONFormat
+	}
+	if fc.ConsoleOutput != nil {
+		config.ConsoleOutput = *fc.ConsoleOutput
+	}
+	if fc.CountField != nil {
+		config.CountField = *fc.CountField
+	}
+	if fc.FileLevel != nil {
+		config.FileLevel = *fc.FileLevel
+	}
+	if fc.FileFormat != nil {
+		config.FileFormat = *fc.FileFormat
+	}
+	if fc.ConsoleLevel != nil {
+		config.ConsoleLevel = *fc.ConsoleLevel
+	}
+	if fc.ConsoleFormat != nil {
+		config.ConsoleFormat = *fc.ConsoleFormat
+	}
+	if fc.ServiceName != nil {
+		config.ServiceName = *fc.ServiceName
+	}
+	if fc.ServiceVersion != nil {
+		config.ServiceVersion = *fc.ServiceVersion
+	}
+	if fc.LogfmtKeys != nil {
+		config.LogfmtKeys = *fc.LogfmtKeys
+	}
+	if fc.ModuleLevels != nil {
+		config.ModuleLevels = *fc.ModuleLevels
+	}
+	if fc.Sinks != nil {
+		config.Sinks = make([]SinkConfig, len(*fc.Sinks))
+		for i, sc := range *fc.Sinks {
+			config.Sinks[i] = sc.sinkConfig()
+		}
+	}
+	if fc.ErrorLog != nil {
+		config.ErrorLog = ErrorLogConfig{
+			Enabled:          fc.ErrorLog.Enabled,
+			Level:            fc.ErrorLog.Level,
+			LogRotatorConfig: fc.ErrorLog.rotatorConfig(),
+		}
+	}
+	if fc.Redaction != nil {
+		config.Redaction = fc.Redaction.redactionConfig()
+	}
+	if fc.Sampling != nil {
+		config.Sampling = fc.Sampling.samplingConfig()
+	}
+	if fc.DedupWindow != nil {
+		config.DedupWindow = time.Duration(*fc.DedupWindow)
+	}
+	if fc.RateLimits != nil {
+		config.RateLimits = make(map[string]RateLimit, len(*fc.RateLimits))
+		for level, limit := range *fc.RateLimits {
+			config.RateLimits[level] = RateLimit(limit)
+		}
+	}
+}
+
+// ParseSize parses a human-friendly byte size such as "200MB", "1.5GiB" or
+// "4096". Units are binary: 1KB is 1024 bytes.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+
+	number, unit := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	if number == "" {
+		return 0, fmt.Errorf("invalid size %q: missing number", s)
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	var multiplier float64
+	switch unit {
+	case "", "B":
+		multiplier = 1
+	case "K", "KB", "KIB":
+		multiplier = 1 << 10
+	case "M", "MB", "MIB":
+		multiplier = 1 << 20
+	case "G", "GB", "GIB":
+		multiplier = 1 << 30
+	case "T", "TB", "TIB":
+		multiplier = 1 << 40
+	default:
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, s[i:])
+	}
+
+	// float64(math.MaxInt64) rounds up to 2^63, which no longer fits
+	size := n * multiplier
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: exceeds %d bytes", s, int64(math.MaxInt64))
+	}
+	return int64(size), nil
+}
+
+// ParseDuration parses a duration like time.ParseDuration but additionally
+// accepts days ("7d") and weeks ("2w"), e.g. "1d12h"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var total time.Duration
+	for rest := s; rest != ""; {
+		i := strings.IndexFunc(rest, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		j := strings.IndexFunc(rest[i:], func(r rune) bool {
+			return (r >= '0' && r <= '9') || r == '.'
+		})
+		if j == -1 {
+			j = len(rest)
+		} else {
+			j += i
+		}
+
+		number, unit := rest[:i], rest[i:j]
+		switch unit {
+		case "d", "w":
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			day := 24 * time.Hour
+			if unit == "w" {
+				day *= 7
+			}
+			total += time.Duration(n * float64(day))
+		default:
+			d, err := time.ParseDuration(number + unit)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: unknown unit %q", s, unit)
+			}
+			total += d
+		}
+		rest = rest[j:]
+	}
+
+	return total, nil
+}
+
+// sizeValue is a byte size that decodes from a number, a string like "200MB"
+// or "unlimited"
+type sizeValue int64
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (v *sizeValue) UnmarshalText(text []byte) error {
+	if isKeyword(text, "unlimited") {
+		*v = Unlimited
+		return nil
+	}
+	n, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*v = sizeValue(n)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *sizeValue) UnmarshalJSON(data []byte) error {
+	return v.UnmarshalText(bytes.Trim(data, `"`))
+}
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (v *sizeValue) UnmarshalTOML(data interface{}) error {
+	return v.UnmarshalText([]byte(fmt.Sprint(data)))
+}
+
+// durationValue is a duration that decodes from a string like "7d", "12h" or
+// "unlimited"
+type durationValue time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (v *durationValue) UnmarshalText(text []byte) error {
+	if isKeyword(text, "unlimited") {
+		*v = Unlimited
+		return nil
+	}
+	d, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *durationValue) UnmarshalJSON(data []byte) error {
+	return v.UnmarshalText(bytes.Trim(data, `"`))
+}
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (v *durationValue) UnmarshalTOML(data interface{}) error {
+	return v.UnmarshalText([]byte(fmt.Sprint(data)))
+}
+
+// backupsValue is a backup count that decodes from a number, "unlimited" or
+// "none"
+type backupsValue int
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (v *backupsValue) UnmarshalText(text []byte) error {
+	switch {
+	case isKeyword(text, "unlimited"):
+		*v = Unlimited
+	case isKeyword(text, "none"):
+		*v = NoBackups
+	default:
+		n, err := strconv.Atoi(strings.TrimSpace(string(text)))
+		if err != nil {
+			return fmt.Errorf("invalid backup count %q (want a number, \"unlimited\" or \"none\")", text)
+		}
+		*v = backupsValue(n)
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *backupsValue) UnmarshalJSON(data []byte) error {
+	return v.UnmarshalText(bytes.Trim(data, `"`))
+}
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (v *backupsValue) UnmarshalTOML(data interface{}) error {
+	return v.UnmarshalText([]byte(fmt.Sprint(data)))
+}
+
+// moduleLevelsValue holds module levels. Files use a mapping from pattern to
+// level; environment variables and overrides use "db=warn,http.*=debug".
+type moduleLevelsValue map[string]string
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (v *moduleLevelsValue) UnmarshalText(text []byte) error {
+	levels := make(moduleLevelsValue)
+	for _, pair := range strings.Split(string(text), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		pattern, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid module level %q (want pattern=level)", pair)
+		}
+		levels[strings.TrimSpace(pattern)] = strings.TrimSpace(level)
+	}
+	*v = levels
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *moduleLevelsValue) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return v.UnmarshalText([]byte(text))
+	}
+	return json.Unmarshal(data, (*map[string]string)(v))
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (v *moduleLevelsValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return v.UnmarshalText([]byte(node.Value))
+	}
+	return node.Decode((*map[string]string)(v))
+}
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (v *moduleLevelsValue) UnmarshalTOML(data interface{}) error {
+	switch data := data.(type) {
+	case string:
+		return v.UnmarshalText([]byte(data))
+	case map[string]interface{}:
+		levels := make(moduleLevelsValue, len(data))
+		for pattern, level := range data {
+			name, ok := level.(string)
+			if !ok {
+				return fmt.Errorf("invalid level %v for module %q", level, pattern)
+			}
+			levels[pattern] = name
+		}
+		*v = levels
+		return nil
+	}
+	return fmt.Errorf("invalid module levels %v", data)
+}
+
+// stringsValue holds a list of strings. Files use a list; environment
+// variables and overrides use "a,b,c".
+type stringsValue []string
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (v *stringsValue) UnmarshalText(text []byte) error {
+	values := stringsValue{}
+	for _, item := range strings.Split(string(text), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	*v = values
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (v *stringsValue) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return v.UnmarshalText([]byte(text))
+	}
+	return json.Unmarshal(data, (*[]string)(v))
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (v *stringsValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return v.UnmarshalText([]byte(node.Value))
+	}
+	return node.Decode((*[]string)(v))
+}
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (v *stringsValue) UnmarshalTOML(data interface{}) error {
+	switch data := data.(type) {
+	case string:
+		return v.UnmarshalText([]byte(data))
+	case []interface{}:
+		values := make(stringsValue, len(data))
+		for i, item := range data {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("invalid list item %v", item)
+			}
+			values[i] = s
+		}
+		*v = values
+		return nil
+	}
+	return fmt.Errorf("invalid list %v", data)
+}
+
+// isKeyword reports whether text equals keyword, ignoring case and spaces
+func isKeyword(text []byte, keyword string) bool {
+	return strings.EqualFold(strings.TrimSpace(string(text)), keyword)
+}
@@ -0,0 +1,250 @@
+package panlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "log_level: debug\nlog_file: logs/app.log\nmax_size: 200MB\nmax_age: 7d\nmax_backups: 4\ncompress: true\n",
+		"config.json": `{"log_level": "debug", "log_file": "logs/app.log", "max_size": "200MB", "max_age": "7d", "max_backups": 4, "compress": true}`,
+		"config.toml": "log_level = \"debug\"\nlog_file = \"logs/app.log\"\nmax_size = \"200MB\"\nmax_age = \"7d\"\nmax_backups = 4\ncompress = true\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join("testdata", name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+		if config.LogLevel != "debug" || config.LogFile != "logs/app.log" {
+			t.Errorf("%s: unexpected level/file %q/%q", name, config.LogLevel, config.LogFile)
+		}
+		if config.MaxSize != 200*1024*1024 {
+			t.Errorf("%s: expected MaxSize 200MB, got %d", name, config.MaxSize)
+		}
+		if config.MaxAge != 7*24*time.Hour {
+			t.Errorf("%s: expected MaxAge 7d, got %v", name, config.MaxAge)
+		}
+		if config.MaxBackups != 4 || !config.Compress {
+			t.Errorf("%s: unexpected backups/compress %d/%v", name, config.MaxBackups, config.Compress)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	cases := map[string]string{
+		"bad_level.yaml": "log_level: verbose\n",
+		"bad_size.yaml":  "max_size: 200XB\n",
+		"unknown.yaml":   "log_levle: info\n",
+		"bad_age.json":   `{"max_age": "7 days"}`,
+		"config.ini":     "log_level=info\n",
+	}
+
+	for name, content := range cases {
+		path := filepath.Join("testdata", name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestConfigSourcesPrecedence(t *testing.T) {
+	path := filepath.Join("testdata", "precedence.yaml")
+	if err := os.WriteFile(path, []byte("log_level: warn\nlog_file: logs/file.log\nmax_size: 10MB\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv("PANLOGTEST_LOG_LEVEL", "error")
+	t.Setenv("PANLOGTEST_MAX_AGE", "36h")
+	t.Setenv("PANLOGTEST_CONSOLE_OUTPUT", "true")
+
+	config, err := ConfigSources{
+		Base:      LoggerConfig{LogLevel: "info", MaxBackups: 9},
+		File:      path,
+		EnvPrefix: "PANLOGTEST",
+		Overrides: map[string]string{"log_level": "debug"},
+	}.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.LogLevel != "debug" {
+		t.Errorf("Expected override to win for log_level, got %q", config.LogLevel)
+	}
+	if config.MaxAge != 36*time.Hour || !config.ConsoleOutput {
+		t.Errorf("Expected env values, got MaxAge %v, ConsoleOutput %v", config.MaxAge, config.ConsoleOutput)
+	}
+	if config.LogFile != "logs/file.log" || config.MaxSize != 10*1024*1024 {
+		t.Errorf("Expected file values, got %q and %d", config.LogFile, config.MaxSize)
+	}
+	if config.MaxBackups != 9 {
+		t.Errorf("Expected base value for MaxBackups, got %d", config.MaxBackups)
+	}
+
+	t.Setenv("PANLOGTEST_MAX_BACKUPS", "many")
+	_, err = ConfigFromEnv("PANLOGTEST")
+	if err == nil || !strings.Contains(err.Error(), "PANLOGTEST_MAX_BACKUPS") {
+		t.Errorf("Expected error naming the variable, got %v", err)
+	}
+}
+
+func TestParseSizeAndDuration(t *testing.T) {
+	sizes := map[string]int64{
+		"4096":   4096,
+		"512KB":  512 * 1024,
+		"1.5GiB": 3 * 512 * 1024 * 1024,
+		"200 mb": 200 * 1024 * 1024,
+	}
+	for input, want := range sizes {
+		got, err := ParseSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"KB", "5XB", "99999999T", "1" + strings.Repeat("0", 30)} {
+		if got, err := ParseSize(input); err == nil {
+			t.Errorf("ParseSize(%q) = %d: expected an error", input, got)
+		}
+	}
+
+	durations := map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		"1w":    7 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"90m":   90 * time.Minute,
+		"0":     0,
+	}
+	for input, want := range durations {
+		got, err := ParseDuration(input)
+		if err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "d", "7days", "-1h"} {
+		if _, err := ParseDuration(input); err == nil {
+			t.Errorf("ParseDuration(%q): expected an error", input)
+		}
+	}
+}
+
+func TestLoadConfigRetentionKeywords(t *testing.T) {
+	path := filepath.Join("testdata", "keywords.yaml")
+	content := "max_size: unlimited\nmax_age: Unlimited\nmax_backups: none\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.MaxSize != Unlimited || config.MaxAge != Unlimited || config.MaxBackups != NoBackups {
+		t.Errorf("Unexpected retention values %d/%v/%d", config.MaxSize, config.MaxAge, config.MaxBackups)
+	}
+
+	// Validation errors are aggregated
+	path = filepath.Join("testdata", "invalid.yaml")
+	content = "log_level: loud\nmax_backups: none\ncompress: true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	_, err = LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "LogLevel") || !strings.Contains(err.Error(), "Compress") {
+		t.Errorf("Expected aggregated validation error, got %v", err)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path := filepath.Join("testdata", "watch.yaml")
+	if err := os.WriteFile(path, []byte("log_level: info\nlog_file: testdata/watch.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	watcher, err := logger.WatchConfig(ConfigSources{File: path}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to watch config: %v", err)
+	}
+	defer watcher.Close()
+
+	if err := os.WriteFile(path, []byte("log_level: debug\nlog_file: testdata/watch.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logger.GetLevel() != logrus.DebugLevel {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the config to be reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLoadConfigModuleLevels(t *testing.T) {
+	path := filepath.Join("testdata", "modules.toml")
+	content := "log_level = \"info\"\n\n[module_levels]\ndb = \"warn\"\n\"http.*\" = \"debug\"\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.ModuleLevels["db"] != "warn" || config.ModuleLevels["http.*"] != "debug" {
+		t.Errorf("Unexpected module levels %v", config.ModuleLevels)
+	}
+
+	t.Setenv("PANLOGMOD_MODULE_LEVELS", "auth=debug, db=error")
+	config, err = ConfigFromEnv("PANLOGMOD")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.ModuleLevels["auth"] != "debug" || config.ModuleLevels["db"] != "error" {
+		t.Errorf("Unexpected module levels %v", config.ModuleLevels)
+	}
+}
+
+func TestLoadConfigErrorLog(t *testing.T) {
+	path := filepath.Join("testdata", "error_log.json")
+	content := `{"log_file": "logs/app.log", "error_log": {"enabled": true, "level": "error", "max_size": "10MB", "max_backups": 20}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.ErrorLog.Enabled || config.ErrorLog.Level != "error" ||
+		config.ErrorLog.MaxSize != 10*1024*1024 || config.ErrorLog.MaxBackups != 20 {
+		t.Errorf("Unexpected error log config %+v", config.ErrorLog)
+	}
+	if got := config.errorLogRotatorConfig().FilePath; got != "logs/app.error.log" {
+		t.Errorf("Expected derived error log path, got %q", got)
+	}
+}
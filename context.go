@@ -0,0 +1,141 @@
+package panlog
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDField is the entry field carrying the request ID of a context
+const RequestIDField = "request_id"
+
+// ContextExtractor returns the fields to attach to entries logged with a
+// context. It returns nil when the context carries nothing of interest.
+type ContextExtractor func(ctx context.Context) logrus.Fields
+
+// contextKey is the type of the context keys defined by this package
+type contextKey int
+
+const (
+	traceparentKey contextKey = iota
+	requestIDKey
+)
+
+// defaultExtractors are registered on every new logger
+var defaultExtractors = []ContextExtractor{TraceparentExtractor, RequestIDExtractor}
+
+// Ctx returns an entry for ctx with the fields of every registered
+// extractor, so that every entry logged while handling a request can be
+// correlated
+func (l *Logger) Ctx(ctx context.Context) *logrus.Entry {
+	return l.WithContext(ctx).WithFields(l.contextFields(ctx))
+}
+
+// Ctx returns an entry of the module for ctx with the fields of the parent
+// logger's extractors
+func (m *ModuleLogger) Ctx(ctx context.Context) *logrus.Entry {
+	return m.WithContext(ctx).WithFields(m.parent.contextFields(ctx))
+}
+
+// AddContextExtractor registers an extractor used by Ctx. Extractors run in
+// registration order; later ones override the fields of earlier ones.
+func (l *Logger) AddContextExtractor(extractor ContextExtractor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.extractors = append(l.extractors, extractor)
+}
+
+// contextFields runs the extractors on ctx
+func (l *Logger) contextFields(ctx context.Context) logrus.Fields {
+	l.mu.Lock()
+	extractors := l.extractors
+	l.mu.Unlock()
+
+	fields := logrus.Fields{}
+	if ctx == nil {
+		return fields
+	}
+	for _, extractor := range extractors {
+		for key, value := range extractor(ctx) {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// ContextWithTraceparent returns a context carrying a W3C traceparent
+// header value, e.g. from an incoming request's "traceparent" header
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentKey, traceparent)
+}
+
+// ContextWithRequestID returns a context carrying a request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// TraceparentExtractor extracts the trace ID, span ID and trace flags of a
+// traceparent set with ContextWithTraceparent. Invalid values are ignored.
+func TraceparentExtractor(ctx context.Context) logrus.Fields {
+	traceparent, _ := ctx.Value(traceparentKey).(string)
+	traceID, spanID, flags, ok := parseTraceparent(traceparent)
+	if !ok {
+		return nil
+	}
+	return logrus.Fields{TraceIDField: traceID, SpanIDField: spanID, TraceFlagsField: flags}
+}
+
+// RequestIDExtractor extracts a request ID set with ContextWithRequestID
+func RequestIDExtractor(ctx context.Context) logrus.Fields {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	if requestID == "" {
+		return nil
+	}
+	return logrus.Fields{RequestIDField: requestID}
+}
+
+// ContextKeyExtractor returns an extractor attaching the value stored under
+// key, if any, as field
+func ContextKeyExtractor(key interface{}, field string) ContextExtractor {
+	return func(ctx context.Context) logrus.Fields {
+		value := ctx.Value(key)
+		if value == nil {
+			return nil
+		}
+		return logrus.Fields{field: value}
+	}
+}
+
+// parseTraceparent parses a W3C traceparent such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func parseTraceparent(traceparent string) (traceID, spanID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// Version 00 has exactly four parts; later versions may append more
+	if version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", "", false
+	}
+	if !isLowerHex(version, 2) || !isLowerHex(traceID, 32) || !isLowerHex(spanID, 16) || !isLowerHex(flags, 2) {
+		return "", "", "", false
+	}
+	if traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return "", "", "", false
+	}
+	return traceID, spanID, flags, true
+}
+
+// isLowerHex reports whether s is n lowercase hex digits
+func isLowerHex(s string, n int) bool {
+	if len(s) != n || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
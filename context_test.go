@@ -0,0 +1,103 @@
+package panlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type tenantKey struct{}
+
+func TestLoggerCtx(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := NewLogger(LoggerConfig{
+		Format: "json",
+		Sinks:  []SinkConfig{{Name: "buffer", Sink: NewWriterSink(&out)}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.AddContextExtractor(ContextKeyExtractor(tenantKey{}, "tenant"))
+
+	ctx := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = ContextWithRequestID(ctx, "req-42")
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+
+	logger.Ctx(ctx).WithField("user", "alice").Info("Handled request")
+	logger.Module("db").Ctx(ctx).Info("Query")
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(lines))
+	}
+
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("Failed to parse entry: %v", err)
+		}
+		expected := map[string]interface{}{
+			TraceIDField:    "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanIDField:     "00f067aa0ba902b7",
+			TraceFlagsField: "01",
+			RequestIDField:  "req-42",
+			"tenant":        "acme",
+		}
+		for key, value := range expected {
+			if entry[key] != value {
+				t.Errorf("Entry %d: expected %s=%v, got %v", i, key, value, entry[key])
+			}
+		}
+	}
+}
+
+func TestLoggerCtxWithoutValues(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{Sinks: []SinkConfig{{Name: "discard", Sink: NewWriterSink(&bytes.Buffer{})}}})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	entry := logger.Ctx(context.Background())
+	if len(entry.Data) != 0 {
+		t.Errorf("Expected no fields for an empty context, got %v", entry.Data)
+	}
+	if entry.Context == nil {
+		t.Errorf("Expected the entry to carry the context")
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if traceID, spanID, flags, ok := parseTraceparent(valid); !ok ||
+		traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" || flags != "01" {
+		t.Errorf("Failed to parse %q", valid)
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, value := range invalid {
+		if _, _, _, ok := parseTraceparent(value); ok {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+
+	if fields := TraceparentExtractor(ContextWithTraceparent(context.Background(), "garbage")); fields != nil {
+		t.Errorf("Expected no fields for invalid traceparent, got %v", fields)
+	}
+	if _, ok := logrus.Fields(RequestIDExtractor(context.Background()))[RequestIDField]; ok {
+		t.Errorf("Expected no request ID without one in the context")
+	}
+}
@@ -0,0 +1,136 @@
+package panlog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CounterSnapshot holds the entry counts collected by a LevelCounter
+type CounterSnapshot struct {
+	Since  time.Time                          // Start of the counting period
+	Until  time.Time                          // Time the snapshot was taken
+	Levels map[logrus.Level]uint64            // Entries per level
+	Fields map[string]map[logrus.Level]uint64 // Entries per field value and level
+	Total  uint64                             // Total number of entries
+}
+
+// LevelCounter is a logrus hook that counts entries per level and,
+// optionally, per value of a chosen field
+type LevelCounter struct {
+	mu     sync.Mutex
+	field  string
+	since  time.Time
+	levels map[logrus.Level]uint64
+	fields map[string]map[logrus.Level]uint64
+}
+
+// NewLevelCounter creates a counter hook. If field is not empty, entries
+// carrying that field are additionally counted per field value.
+func NewLevelCounter(field string) *LevelCounter {
+	return &LevelCounter{
+		field:  field,
+		since:  time.Now(),
+		levels: make(map[logrus.Level]uint64),
+		fields: make(map[string]map[logrus.Level]uint64),
+	}
+}
+
+// Levels implements logrus.Hook
+func (c *LevelCounter) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (c *LevelCounter) Fire(entry *logrus.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.levels[entry.Level]++
+
+	if c.field == "" {
+		return nil
+	}
+	value, ok := entry.Data[c.field]
+	if !ok {
+		return nil
+	}
+	key := fmt.Sprint(value)
+	counts, ok := c.fields[key]
+	if !ok {
+		counts = make(map[logrus.Level]uint64)
+		c.fields[key] = counts
+	}
+	counts[entry.Level]++
+	return nil
+}
+
+// Count returns the number of entries counted at the given level
+func (c *LevelCounter) Count(level logrus.Level) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.levels[level]
+}
+
+// Snapshot returns a copy of the current counters
+func (c *LevelCounter) Snapshot() CounterSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.snapshot()
+}
+
+// Reset returns a snapshot of the current counters and starts a new
+// counting period, which is convenient for periodic reporting
+func (c *LevelCounter) Reset() CounterSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot := c.snapshot()
+	c.since = snapshot.Until
+	c.levels = make(map[logrus.Level]uint64)
+	c.fields = make(map[string]map[logrus.Level]uint64)
+	return snapshot
+}
+
+// setField changes the field counters are broken down by, discarding the
+// per-field counts if it differs from the current one
+func (c *LevelCounter) setField(field string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if field != c.field {
+		c.field = field
+		c.fields = make(map[string]map[logrus.Level]uint64)
+	}
+}
+
+// snapshot copies the counters; the caller must hold c.mu
+func (c *LevelCounter) snapshot() CounterSnapshot {
+	snapshot := CounterSnapshot{
+		Since:  c.since,
+		Until:  time.Now(),
+		Levels: make(map[logrus.Level]uint64, len(c.levels)),
+	}
+
+	for level, count := range c.levels {
+		snapshot.Levels[level] = count
+		snapshot.Total += count
+	}
+
+	if c.field != "" {
+		snapshot.Fields = make(map[string]map[logrus.Level]uint64, len(c.fields))
+		for value, counts := range c.fields {
+			copied := make(map[logrus.Level]uint64, len(counts))
+			for level, count := range counts {
+				copied[level] = count
+			}
+			snapshot.Fields[value] = copied
+		}
+	}
+
+	return snapshot
+}
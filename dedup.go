@@ -0,0 +1,283 @@
+package panlog
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields of the entries summarizing suppressed entries
+const (
+	RepeatedField    = "repeated"     // Number of duplicates collapsed into the entry
+	RateLimitedField = "rate_limited" // Number of entries dropped by the rate limit
+)
+
+// RateLimit is a token bucket limiting the entries of a level written to
+// the outputs. Entries exceeding the limit are dropped; the next entry
+// written at that level is preceded by a summary of the dropped count.
+type RateLimit struct {
+	Rate  float64 // Entries per second
+	Burst int     // Bucket size (defaults to Rate, rounded up)
+}
+
+// dedupKey identifies identical entries: same level, message and fields
+type dedupKey struct {
+	level   logrus.Level
+	message string
+	fields  string
+}
+
+// newDedupKey fingerprints an entry
+func newDedupKey(entry *logrus.Entry) dedupKey {
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%v\x00", key, entry.Data[key])
+	}
+	return dedupKey{level: entry.Level, message: entry.Message, fields: b.String()}
+}
+
+// dedupWindow tracks the duplicates of an entry
+type dedupWindow struct {
+	start    time.Time
+	repeated int
+	last     *logrus.Entry // Last duplicate, written with the repeated count
+}
+
+// deduper is the router filter collapsing identical entries. The first
+// entry is written at once; its duplicates within the window are counted
+// and written as a single entry with the RepeatedField when the window
+// closes.
+type deduper struct {
+	emit func(entry *logrus.Entry)
+
+	mu         sync.Mutex // guards the fields below
+	window     time.Duration
+	windows    map[dedupKey]*dedupWindow
+	suppressed uint64
+
+	ctl    sync.Mutex // serializes swap and close
+	ticker *ticker
+}
+
+// newDeduper creates a deduper writing collapsed entries with emit
+func newDeduper(window time.Duration, emit func(entry *logrus.Entry)) *deduper {
+	d := &deduper{emit: emit}
+	d.swap(window)
+	return d
+}
+
+// allow implements entryFilter
+func (d *deduper) allow(entry *logrus.Entry) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.window <= 0 {
+		return true
+	}
+
+	key := newDedupKey(entry)
+	w, ok := d.windows[key]
+	if !ok {
+		d.windows[key] = &dedupWindow{start: time.Now()}
+		return true
+	}
+	w.repeated++
+	w.last = entry
+	d.suppressed++
+	return false
+}
+
+// flush closes the windows older than the window duration, or all of them
+// if all is set, writing the collapsed duplicates
+func (d *deduper) flush(all bool) {
+	now := time.Now()
+
+	d.mu.Lock()
+	var closed []*dedupWindow
+	for key, w := range d.windows {
+		if all || now.Sub(w.start) >= d.window {
+			delete(d.windows, key)
+			if w.repeated > 0 {
+				closed = append(closed, w)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	sort.Slice(closed, func(i, j int) bool {
+		return closed[i].last.Time.Before(closed[j].last.Time)
+	})
+	for _, w := range closed {
+		entry := w.last.WithField(RepeatedField, w.repeated)
+		entry.Time = w.last.Time
+		entry.Level = w.last.Level
+		entry.Message = w.last.Message
+		entry.Caller = w.last.Caller
+		d.emit(entry)
+	}
+}
+
+// swap closes the open windows and applies a new window duration; zero
+// disables deduplication
+func (d *deduper) swap(window time.Duration) {
+	d.ctl.Lock()
+	defer d.ctl.Unlock()
+
+	if d.ticker != nil {
+		d.ticker.stop()
+		d.ticker = nil
+	}
+	d.flush(true)
+
+	d.mu.Lock()
+	d.window = window
+	d.windows = make(map[dedupKey]*dedupWindow)
+	d.mu.Unlock()
+
+	if window > 0 {
+		// Windows close at most a quarter of their duration late
+		d.ticker = startTicker(window/4, func() { d.flush(false) })
+	}
+}
+
+// close writes the pending duplicates and stops deduplication
+func (d *deduper) close() {
+	d.swap(0)
+}
+
+// stats returns deduplication statistics
+func (d *deduper) stats() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return map[string]interface{}{
+		"suppressed":   d.suppressed,
+		"open_windows": len(d.windows),
+	}
+}
+
+// tokenBucket is the state of a RateLimit
+type tokenBucket struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	dropped int
+	logger  *logrus.Logger // Logger of the summary entry
+}
+
+// rateLimiter is the router filter enforcing the per-level RateLimits
+type rateLimiter struct {
+	emit func(entry *logrus.Entry)
+
+	mu      sync.Mutex // guards the fields below
+	buckets map[logrus.Level]*tokenBucket
+	total   uint64
+}
+
+// newRateLimiter creates a rate limiter writing summary entries with emit
+func newRateLimiter(limits map[string]RateLimit, emit func(entry *logrus.Entry)) *rateLimiter {
+	r := &rateLimiter{emit: emit}
+	r.swap(limits)
+	return r
+}
+
+// allow implements entryFilter
+func (r *rateLimiter) allow(entry *logrus.Entry) bool {
+	r.mu.Lock()
+	b, ok := r.buckets[entry.Level]
+	if !ok {
+		r.mu.Unlock()
+		return true
+	}
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		b.dropped++
+		b.logger = entry.Logger
+		r.total++
+		r.mu.Unlock()
+		return false
+	}
+	b.tokens--
+
+	dropped, logger := b.dropped, b.logger
+	b.dropped = 0
+	r.mu.Unlock()
+
+	// Report the entries dropped since the last one written
+	if dropped > 0 {
+		r.emit(rateLimitSummary(logger, entry.Level, dropped))
+	}
+	return true
+}
+
+// rateLimitSummary creates the entry reporting dropped entries
+func rateLimitSummary(logger *logrus.Logger, level logrus.Level, dropped int) *logrus.Entry {
+	entry := logrus.NewEntry(logger).WithField(RateLimitedField, dropped)
+	entry.Time = time.Now()
+	entry.Level = level
+	entry.Message = "Rate limited entries"
+	return entry
+}
+
+// swap applies new limits, reporting the entries dropped under the old ones
+func (r *rateLimiter) swap(limits map[string]RateLimit) {
+	buckets := make(map[logrus.Level]*tokenBucket, len(limits))
+	for name, limit := range limits {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			continue
+		}
+		burst := float64(limit.Burst)
+		if burst == 0 {
+			burst = math.Max(1, math.Ceil(limit.Rate))
+		}
+		buckets[level] = &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: time.Now()}
+	}
+
+	r.mu.Lock()
+	previous := r.buckets
+	r.buckets = buckets
+	r.mu.Unlock()
+
+	levels := make([]logrus.Level, 0, len(previous))
+	for level, b := range previous {
+		if b.dropped > 0 {
+			levels = append(levels, level)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	for _, level := range levels {
+		b := previous[level]
+		r.emit(rateLimitSummary(b.logger, level, b.dropped))
+	}
+}
+
+// close reports the dropped entries and removes the limits
+func (r *rateLimiter) close() {
+	r.swap(nil)
+}
+
+// stats returns rate limiting statistics
+func (r *rateLimiter) stats() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return map[string]interface{}{
+		"rate_limited": r.total,
+	}
+}
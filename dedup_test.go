@@ -0,0 +1,176 @@
+package panlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDedupCollapsesDuplicates(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{DedupWindow: time.Hour})
+
+	for i := 0; i < 1000; i++ {
+		logger.WithField("conn", 7).Error("Connection reset")
+	}
+	logger.WithField("conn", 8).Error("Connection reset")
+	logger.deduper.flush(true)
+
+	entries := bufferEntries(t, out)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	if _, ok := entries[0][RepeatedField]; ok || entries[0]["conn"] != float64(7) {
+		t.Errorf("Expected first occurrence to be written as is, got %v", entries[0])
+	}
+	if entries[1]["conn"] != float64(8) {
+		t.Errorf("Expected entry with other fields to be written, got %v", entries[1])
+	}
+	collapsed := entries[2]
+	if collapsed["msg"] != "Connection reset" || collapsed["conn"] != float64(7) ||
+		collapsed[RepeatedField] != float64(999) || collapsed["level"] != "error" {
+		t.Errorf("Unexpected collapsed entry %v", collapsed)
+	}
+}
+
+func TestDedupWindowCloses(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{DedupWindow: 40 * time.Millisecond})
+
+	logger.Warn("Disk almost full")
+	logger.Warn("Disk almost full")
+	logger.Warn("Disk almost full")
+	time.Sleep(200 * time.Millisecond)
+	logger.Warn("Disk almost full")
+	logger.Close()
+
+	entries := bufferEntries(t, out)
+	if len(entries) != 3 {
+		t.Fatalf("Expected first, collapsed and new first entry, got %d entries", len(entries))
+	}
+	if entries[1][RepeatedField] != float64(2) {
+		t.Errorf("Expected 2 repeats when the window closed, got %v", entries[1][RepeatedField])
+	}
+	if _, ok := entries[2][RepeatedField]; ok {
+		t.Errorf("Expected a new window after the first one closed, got %v", entries[2])
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{RateLimits: map[string]RateLimit{"error": {Rate: 0.001, Burst: 5}}})
+
+	for i := 0; i < 100; i++ {
+		logger.WithField("i", i).Error("Flood")
+		logger.WithField("i", i).Info("Not limited")
+	}
+
+	counts := countMessages(bufferEntries(t, out))
+	if counts["Flood"] != 5 {
+		t.Errorf("Expected burst of 5 errors, got %d", counts["Flood"])
+	}
+	if counts["Not limited"] != 100 {
+		t.Errorf("Expected info entries not to be limited, got %d", counts["Not limited"])
+	}
+	if stats := logger.GetStats()["rate_limits"].(map[string]interface{}); stats["rate_limited"] != uint64(95) {
+		t.Errorf("Expected 95 rate limited entries, got %v", stats["rate_limited"])
+	}
+
+	logger.Close()
+	entries := bufferEntries(t, out)
+	summary := entries[len(entries)-1]
+	if summary["msg"] != "Rate limited entries" || summary[RateLimitedField] != float64(95) {
+		t.Errorf("Unexpected summary entry %v", summary)
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{RateLimits: map[string]RateLimit{"info": {Rate: 20, Burst: 1}}})
+
+	logger.Info("First")
+	logger.Info("Dropped")
+	time.Sleep(100 * time.Millisecond)
+	logger.Info("Refilled")
+
+	entries := bufferEntries(t, out)
+	var msgs []string
+	for _, entry := range entries {
+		msgs = append(msgs, entry["msg"].(string))
+	}
+	if got := strings.Join(msgs, ","); got != "First,Rate limited entries,Refilled" {
+		t.Errorf("Expected summary before the next written entry, got %s", got)
+	}
+	if entries[1][RateLimitedField] != float64(1) || entries[1]["level"] != logrus.InfoLevel.String() {
+		t.Errorf("Unexpected summary entry %v", entries[1])
+	}
+}
+
+func TestErrorLogFiltered(t *testing.T) {
+	dir := filepath.Join("testdata", "filtered")
+	os.RemoveAll(dir)
+
+	logger, err := NewLogger(LoggerConfig{
+		LogFile:     filepath.Join(dir, "app.log"),
+		Format:      "json",
+		DedupWindow: time.Hour,
+		RateLimits:  map[string]RateLimit{"error": {Rate: 1, Burst: 1}},
+		ErrorLog:    ErrorLogConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		logger.Error("same")
+	}
+	logger.Close()
+
+	for _, name := range []string{"app.log", "app.error.log"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		entries := bufferEntries(t, bytes.NewBuffer(content))
+		if len(entries) != 2 || entries[1][RepeatedField] != float64(999) {
+			t.Errorf("Expected first and collapsed entry in %s, got %v", name, entries)
+		}
+	}
+}
+
+func TestDedupValidation(t *testing.T) {
+	_, err := NewLogger(LoggerConfig{
+		DedupWindow: -time.Second,
+		RateLimits:  map[string]RateLimit{"warn": {Rate: 0}, "noisy": {Rate: 1, Burst: -1}},
+	})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	for _, field := range []string{"DedupWindow", `RateLimits["warn"].Rate`, `RateLimits["noisy"]`, `RateLimits["noisy"].Burst`} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected error for %s, got %v", field, err)
+		}
+	}
+}
+
+func TestLoadConfigDedup(t *testing.T) {
+	path := filepath.Join("testdata", "dedup.json")
+	content := `{"dedup_window": "5s", "rate_limits": {"error": {"rate": 50, "burst": 100}}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.DedupWindow != 5*time.Second {
+		t.Errorf("Expected 5s dedup window, got %v", config.DedupWindow)
+	}
+	if limit := config.RateLimits["error"]; limit.Rate != 50 || limit.Burst != 100 {
+		t.Errorf("Unexpected rate limits %+v", config.RateLimits)
+	}
+}
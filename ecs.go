@@ -0,0 +1,110 @@
+package panlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ECSVersion is the Elastic Common Schema version of ECSFormatter entries
+const ECSVersion = "8.11.0"
+
+// ECSFormatter renders entries as Elastic Common Schema JSON documents.
+// Dotted field names such as "http.request.method" are nested into objects;
+// the error set by WithError becomes error.message, error.type and, when the
+// error formats differently with %+v, error.stack_trace.
+type ECSFormatter struct {
+	ServiceName    string // service.name (omitted when empty)
+	ServiceVersion string // service.version (omitted when empty)
+}
+
+// ecsTimestampFormat is the @timestamp layout, with millisecond precision
+const ecsTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ecsTopLevel are the keys the ECS logging spec keeps dotted at the top level
+var ecsTopLevel = map[string]bool{"@timestamp": true, "log.level": true, "message": true, "ecs.version": true}
+
+// Format implements logrus.Formatter
+func (f *ECSFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	doc := map[string]interface{}{}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := entry.Data[key]
+		if key == logrus.ErrorKey {
+			if err, ok := value.(error); ok {
+				setECSField(doc, "error.message", err.Error())
+				setECSField(doc, "error.type", fmt.Sprintf("%T", err))
+				if trace := fmt.Sprintf("%+v", err); trace != err.Error() {
+					setECSField(doc, "error.stack_trace", trace)
+				}
+				continue
+			}
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		if ecsTopLevel[key] {
+			// Keep fields that clash with the reserved keys
+			key = "fields." + key
+		}
+		setECSField(doc, key, value)
+	}
+
+	if entry.HasCaller() {
+		setECSField(doc, "log.origin.file.name", entry.Caller.File)
+		setECSField(doc, "log.origin.file.line", entry.Caller.Line)
+		setECSField(doc, "log.origin.function", entry.Caller.Function)
+	}
+	if f.ServiceName != "" {
+		setECSField(doc, "service.name", f.ServiceName)
+	}
+	if f.ServiceVersion != "" {
+		setECSField(doc, "service.version", f.ServiceVersion)
+	}
+
+	doc["@timestamp"] = entry.Time.Format(ecsTimestampFormat)
+	doc["log.level"] = entry.Level.String()
+	doc["message"] = entry.Message
+	doc["ecs.version"] = ECSVersion
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ECS entry: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// setECSField sets a dotted key as nested objects. When a prefix of the key
+// already holds a value that is not an object, the rest of the key is kept
+// dotted at that level. Fields are set in sorted order, so "a" is always set
+// before "a.b".
+func setECSField(doc map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	m := doc
+	for i, part := range parts[:len(parts)-1] {
+		next, exists := m[part]
+		if !exists {
+			child := map[string]interface{}{}
+			m[part] = child
+			m = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			m[strings.Join(parts[i:], ".")] = value
+			return
+		}
+		m = child
+	}
+
+	m[parts[len(parts)-1]] = value
+}
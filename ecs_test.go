@@ -0,0 +1,118 @@
+package panlog
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestECSFormatter(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"http.request.method": "GET",
+		"http.response.code":  200,
+		"user":                "alice",
+		"user.id":             7,
+		"message":             "clash",
+	}).WithError(errors.New("connection refused"))
+	entry.Time = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = logrus.ErrorLevel
+	entry.Message = "Request failed"
+
+	data, err := (&ECSFormatter{ServiceName: "api", ServiceVersion: "1.2.3"}).Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to parse ECS entry: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"@timestamp":  "2024-05-01T12:00:00.000Z",
+		"log.level":   "error",
+		"message":     "Request failed",
+		"ecs.version": ECSVersion,
+		"user":        "alice",
+		"user.id":     float64(7),
+	}
+	for key, value := range expected {
+		if doc[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, doc[key])
+		}
+	}
+
+	nested := func(path ...string) interface{} {
+		var v interface{} = doc
+		for _, key := range path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[key]
+		}
+		return v
+	}
+	if method := nested("http", "request", "method"); method != "GET" {
+		t.Errorf("Expected nested http.request.method, got %v", method)
+	}
+	if code := nested("http", "response", "code"); code != float64(200) {
+		t.Errorf("Expected nested http.response.code, got %v", code)
+	}
+	if msg := nested("error", "message"); msg != "connection refused" {
+		t.Errorf("Expected error.message, got %v", msg)
+	}
+	if typ := nested("error", "type"); typ != "*errors.errorString" {
+		t.Errorf("Expected error.type, got %v", typ)
+	}
+	if name := nested("service", "name"); name != "api" {
+		t.Errorf("Expected service.name, got %v", name)
+	}
+	if version := nested("service", "version"); version != "1.2.3" {
+		t.Errorf("Expected service.version, got %v", version)
+	}
+	if clash := nested("fields", "message"); clash != "clash" {
+		t.Errorf("Expected clashing field under fields, got %v", clash)
+	}
+}
+
+func TestFormatOption(t *testing.T) {
+	logFile := filepath.Join("testdata", "format.log")
+	os.Remove(logFile)
+
+	logger, err := NewLogger(LoggerConfig{
+		LogFile:     logFile,
+		Format:      "ecs",
+		ServiceName: "checkout",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.Info("ECS message")
+	logger.Close()
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to parse ECS entry %q: %v", data, err)
+	}
+	if doc["message"] != "ECS message" || doc["ecs.version"] != ECSVersion {
+		t.Errorf("Expected ECS entry, got %v", doc)
+	}
+
+	err = LoggerConfig{Format: "ecs", JSONFormat: true}.Validate()
+	if err == nil {
+		t.Errorf("Expected JSONFormat to conflict with Format")
+	}
+	if err := (LoggerConfig{Format: "xml"}).Validate(); err == nil {
+		t.Errorf("Expected unknown format to be rejected")
+	}
+}
@@ -0,0 +1,71 @@
+package panlog
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorLogConfig configures a second log file that only receives entries at
+// or above a level, e.g. app.error.log next to app.log. The error log is one
+// of the logger's outputs, so sampling, deduplication and rate limits apply
+// to it as well.
+type ErrorLogConfig struct {
+	Enabled bool   // Whether to write the error log
+	Level   string // Minimum level written to the error log (defaults to warn)
+
+	// File and rotation settings. FilePath defaults to LogFile with ".error"
+	// inserted before the extension; zero limits use the LogRotator defaults.
+	LogRotatorConfig
+}
+
+// errorLogRotatorConfig returns the LogRotatorConfig of the error log
+func (c LoggerConfig) errorLogRotatorConfig() LogRotatorConfig {
+	config := c.ErrorLog.LogRotatorConfig
+	if config.FilePath == "" && c.LogFile != "" {
+		ext := filepath.Ext(c.LogFile)
+		config.FilePath = strings.TrimSuffix(c.LogFile, ext) + ".error" + ext
+	}
+	return config
+}
+
+// errorLogLevel returns the validated minimum level of the error log
+func (c LoggerConfig) errorLogLevel() logrus.Level {
+	if c.ErrorLog.Level == "" {
+		return logrus.WarnLevel
+	}
+	return parseLevel(c.ErrorLog.Level)
+}
+
+// openErrorLog opens the error log rotator of config. If current is already
+// open at the same path, it is kept and the returned function applies the
+// new settings to it; nothing changes until that function is called.
+func openErrorLog(config LoggerConfig, current *LogRotator) (*LogRotator, func(), error) {
+	if !config.ErrorLog.Enabled {
+		return nil, func() {}, nil
+	}
+
+	rotatorConfig := config.errorLogRotatorConfig()
+	if current != nil && current.filePath == rotatorConfig.FilePath {
+		apply, err := current.prepareReconfigure(rotatorConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to reconfigure error log: %w", err)
+		}
+		return current, apply, nil
+	}
+
+	rotator, err := NewLogRotator(rotatorConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create error log rotator: %w", err)
+	}
+	return rotator, func() {}, nil
+}
+
+// errorLogOutput returns the output writing to the error log rotator
+func errorLogOutput(config LoggerConfig, rotator *LogRotator) *output {
+	out := newOutput("error_log", "", config.formatter(config.FileFormat, false), rotator)
+	out.level = config.errorLogLevel()
+	return out
+}
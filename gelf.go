@@ -0,0 +1,343 @@
+package panlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GELFFormatter renders entries as GELF 1.1 messages. Entry fields become
+// additional fields prefixed with "_", the level becomes the syslog severity
+// and the first line of the message is the short_message; multi-line
+// messages are also sent whole as full_message.
+type GELFFormatter struct {
+	Host string // host field (defaults to os.Hostname)
+}
+
+// gelfHostname returns the host name, looked up once rather than for every
+// entry
+var gelfHostname = sync.OnceValue(func() string {
+	host, _ := os.Hostname()
+	return host
+})
+
+// gelfFieldName matches the characters allowed in additional field names
+var gelfFieldName = regexp.MustCompile(`[^\w.\-]`)
+
+// Format implements logrus.Formatter
+func (f *GELFFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	host := f.Host
+	if host == "" {
+		host = gelfHostname()
+	}
+
+	short, _, multiline := strings.Cut(entry.Message, "\n")
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": short,
+		"timestamp":     json.Number(strconv.FormatFloat(float64(entry.Time.UnixMilli())/1000, 'f', 3, 64)),
+		"level":         syslogSeverity(entry.Level),
+	}
+	if short == "" {
+		// short_message is mandatory and must not be empty
+		msg["short_message"] = "-"
+	}
+	if multiline {
+		msg["full_message"] = entry.Message
+	}
+	if entry.HasCaller() {
+		msg["_file"] = entry.Caller.File
+		msg["_line"] = entry.Caller.Line
+		msg["_function"] = entry.Caller.Function
+	}
+
+	for key, value := range entry.Data {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		msg[gelfAdditionalField(key)] = value
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal GELF message: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// gelfAdditionalField returns the additional field name of an entry field.
+// "_id" is reserved by GELF, so the id field becomes "_id_".
+func gelfAdditionalField(key string) string {
+	name := "_" + gelfFieldName.ReplaceAllString(key, "_")
+	if name == "_id" {
+		return "_id_"
+	}
+	return name
+}
+
+// GELFConfig configures a GELF sink
+type GELFConfig struct {
+	Network   string        // udp (default) or tcp
+	Address   string        // host:port of the Graylog input
+	Host      string        // host field (defaults to os.Hostname)
+	Compress  bool          // Whether to gzip udp messages
+	ChunkSize int           // Maximum udp datagram size (defaults to 1420)
+	Timeout   time.Duration // Dial and write timeout (defaults to 5s)
+
+	// Minimum time between reconnect attempts (defaults to 1s). Entries
+	// written while disconnected in between fail at once.
+	ReconnectInterval time.Duration
+}
+
+// Limits of GELF chunking
+const (
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// GELFSink sends entries to a Graylog GELF input. Over udp, messages larger
+// than ChunkSize are split into GELF chunks; over tcp, messages are framed by
+// a null byte. The sink renders entries with a GELFFormatter, so its Format
+// setting is ignored.
+type GELFSink struct {
+	config    GELFConfig
+	formatter *GELFFormatter
+
+	mu         sync.Mutex
+	conn       net.Conn
+	lastDial   time.Time
+	reconnects uint64
+	chunked    uint64
+}
+
+// NewGELFSink connects to the GELF input described by config
+func NewGELFSink(config GELFConfig) (*GELFSink, error) {
+	switch config.Network {
+	case "":
+		config.Network = "udp"
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported GELF network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("GELF address is required")
+	}
+	if config.Compress && config.Network == "tcp" {
+		return nil, fmt.Errorf("GELF over tcp does not support compression")
+	}
+	if config.ChunkSize == 0 {
+		config.ChunkSize = 1420
+	}
+	if config.ChunkSize <= gelfChunkHeaderSize {
+		return nil, fmt.Errorf("GELF chunk size %d is too small", config.ChunkSize)
+	}
+	if config.Host == "" {
+		config.Host = gelfHostname()
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.ReconnectInterval == 0 {
+		config.ReconnectInterval = time.Second
+	}
+
+	s := &GELFSink{config: config, formatter: &GELFFormatter{Host: config.Host}}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WriteEntry implements Sink
+func (s *GELFSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	msg, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	msg = bytes.TrimRight(msg, "\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.reconnect(); err != nil {
+			return err
+		}
+	}
+
+	if s.config.Network == "tcp" {
+		err = s.write(append(msg, 0))
+		if err != nil {
+			// Retry once on a fresh connection if the input went away
+			s.disconnect()
+			if rerr := s.reconnect(); rerr != nil {
+				return err
+			}
+			err = s.write(append(msg, 0))
+		}
+		return err
+	}
+
+	if s.config.Compress {
+		if msg, err = gzipBytes(msg); err != nil {
+			return fmt.Errorf("failed to compress GELF message: %w", err)
+		}
+	}
+	return s.writeChunked(msg)
+}
+
+// Close implements Sink
+func (s *GELFSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.disconnect()
+}
+
+// GetStats returns statistics about the GELF connection
+func (s *GELFSink) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"network":    s.config.Network,
+		"address":    s.config.Address,
+		"connected":  s.conn != nil,
+		"reconnects": s.reconnects,
+		"chunked":    s.chunked,
+	}
+}
+
+// connect dials the GELF input; the caller must hold s.mu unless the sink is
+// not shared yet
+func (s *GELFSink) connect() error {
+	s.lastDial = time.Now()
+
+	conn, err := net.DialTimeout(s.config.Network, s.config.Address, s.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to GELF input: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// reconnect dials the GELF input unless the last attempt was less than
+// ReconnectInterval ago, so that an unreachable input does not delay every
+// entry by a dial timeout; the caller must hold s.mu
+func (s *GELFSink) reconnect() error {
+	if time.Since(s.lastDial) < s.config.ReconnectInterval {
+		return fmt.Errorf("not connected to GELF input")
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	s.reconnects++
+	return nil
+}
+
+// disconnect closes the connection; the caller must hold s.mu
+func (s *GELFSink) disconnect() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// write sends data on the connection; the caller must hold s.mu
+func (s *GELFSink) write(data []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	_, err := s.conn.Write(data)
+	return err
+}
+
+// writeChunked sends a udp message, split into GELF chunks if it does not
+// fit in a datagram; the caller must hold s.mu
+func (s *GELFSink) writeChunked(msg []byte) error {
+	if len(msg) <= s.config.ChunkSize {
+		return s.write(msg)
+	}
+
+	dataSize := s.config.ChunkSize - gelfChunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return fmt.Errorf("GELF message of %d bytes needs %d chunks, more than %d", len(msg), count, gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate GELF message id: %w", err)
+	}
+
+	chunk := make([]byte, 0, s.config.ChunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*dataSize:end]...)
+		if err := s.write(chunk); err != nil {
+			return err
+		}
+	}
+	s.chunked++
+	return nil
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gelfConfigFromOptions applies string options from config files
+func gelfConfigFromOptions(config GELFConfig, options map[string]string) (GELFConfig, error) {
+	for key, value := range options {
+		var err error
+		switch key {
+		case "network":
+			config.Network = value
+		case "address":
+			config.Address = value
+		case "host":
+			config.Host = value
+		case "compress":
+			config.Compress, err = strconv.ParseBool(value)
+		case "chunk_size":
+			config.ChunkSize, err = strconv.Atoi(value)
+		case "timeout":
+			config.Timeout, err = ParseDuration(value)
+		case "reconnect_interval":
+			config.ReconnectInterval, err = ParseDuration(value)
+		default:
+			err = fmt.Errorf("unknown option")
+		}
+		if err != nil {
+			return config, fmt.Errorf("gelf option %s: %w", key, err)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,212 @@
+package panlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parseGELF decodes a GELF message
+func parseGELF(t *testing.T, data []byte) map[string]interface{} {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("Failed to parse GELF message %q: %v", data, err)
+	}
+	return msg
+}
+
+func TestGELFFormatter(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"user":   "alice",
+		"id":     42,
+		"error":  errors.New("boom"),
+		"a key!": true,
+	})
+	entry.Time = time.Unix(1700000000, 250*int64(time.Millisecond))
+	entry.Level = logrus.ErrorLevel
+	entry.Message = "Request failed\nstack trace"
+
+	data, err := (&GELFFormatter{Host: "web-1"}).Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+	msg := parseGELF(t, data)
+
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "web-1",
+		"short_message": "Request failed",
+		"full_message":  "Request failed\nstack trace",
+		"timestamp":     1700000000.25,
+		"level":         float64(3),
+		"_user":         "alice",
+		"_id_":          float64(42),
+		"_error":        "boom",
+		"_a_key_":       true,
+	}
+	for key, value := range expected {
+		if msg[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, msg[key])
+		}
+	}
+	if _, ok := msg["_id"]; ok {
+		t.Errorf("Expected reserved _id field to be renamed")
+	}
+}
+
+func TestGELFSinkUDPChunked(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := NewGELFSink(GELFConfig{Address: conn.LocalAddr().String(), Compress: true, ChunkSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to create GELF sink: %v", err)
+	}
+	defer sink.Close()
+
+	// Random-looking content so that the compressed message needs chunks
+	var message strings.Builder
+	for i := 0; i < 200; i++ {
+		message.WriteString(time.Duration(i * 7919).String())
+	}
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.InfoLevel
+	entry.Message = message.String()
+	if err := sink.WriteEntry(entry, nil); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+
+	var id []byte
+	chunks := map[byte][]byte{}
+	var count byte
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for count == 0 || len(chunks) < int(count) {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		if n > 100 || buf[0] != 0x1e || buf[1] != 0x0f {
+			t.Fatalf("Expected GELF chunk of at most 100 bytes, got %d bytes", n)
+		}
+		if id == nil {
+			id = append([]byte(nil), buf[2:10]...)
+		} else if !bytes.Equal(id, buf[2:10]) {
+			t.Fatalf("Expected the same message id in every chunk")
+		}
+		count = buf[11]
+		chunks[buf[10]] = append([]byte(nil), buf[12:n]...)
+	}
+
+	var compressed []byte
+	for i := byte(0); i < count; i++ {
+		compressed = append(compressed, chunks[i]...)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Failed to read gzip message: %v", err)
+	}
+	data, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("Failed to decompress message: %v", err)
+	}
+	if msg := parseGELF(t, data); msg["short_message"] != message.String() {
+		t.Errorf("Expected reassembled message")
+	}
+}
+
+func TestGELFSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	messages := make(chan []byte, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			frame, err := r.ReadBytes(0)
+			if err != nil {
+				return
+			}
+			messages <- frame[:len(frame)-1]
+		}
+	}()
+
+	logger, err := NewLogger(LoggerConfig{
+		Sinks: []SinkConfig{{Type: "gelf", GELF: GELFConfig{Network: "tcp", Address: ln.Addr().String()}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WithField("request", "abc").Warn("First message")
+	logger.Info("Second message")
+
+	for _, expected := range []string{"First message", "Second message"} {
+		select {
+		case data := <-messages:
+			if msg := parseGELF(t, data); msg["short_message"] != expected {
+				t.Errorf("Expected %q, got %v", expected, msg["short_message"])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for GELF message")
+		}
+	}
+}
+
+func TestGELFSinkReconnectInterval(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	sink, err := NewGELFSink(GELFConfig{Network: "tcp", Address: ln.Addr().String(), ReconnectInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create GELF sink: %v", err)
+	}
+	defer sink.Close()
+
+	// Lose the connection and the input
+	sink.mu.Lock()
+	sink.disconnect()
+	sink.mu.Unlock()
+	ln.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "Dropped"
+	for i := 0; i < 3; i++ {
+		if err := sink.WriteEntry(entry, nil); err == nil || !strings.Contains(err.Error(), "not connected") {
+			t.Errorf("Expected entry to fail without dialing, got %v", err)
+		}
+	}
+	if reconnects := sink.GetStats()["reconnects"]; reconnects != uint64(0) {
+		t.Errorf("Expected no reconnect attempt within the interval, got %v", reconnects)
+	}
+
+	// Once the interval has passed, the input is dialed again
+	sink.mu.Lock()
+	sink.lastDial = time.Time{}
+	sink.mu.Unlock()
+	if err := sink.WriteEntry(entry, nil); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Errorf("Expected reconnect attempt, got %v", err)
+	}
+}
@@ -2,6 +2,10 @@ module github.com/Kanixon/panlog
 
 go 1.23.2
 
-require github.com/sirupsen/logrus v1.9.3
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/sirupsen/logrus v1.9.3
+	gopkg.in/yaml.v3 v3.0.1
+)
 
-require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8
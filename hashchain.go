@@ -0,0 +1,369 @@
+package panlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// A hash chain makes log files tamper-evident. Every line of a log file is
+// linked to the previous one by a SHA-256 hash recorded in a sidecar file
+// named after the log file with a .chain extension (app.log.chain, or
+// app-2024-01-02-150405.log.chain for a backup, compressed or not):
+//
+//	prev <hash the chain continues from>
+//	<sha256(prev || line 1)>
+//	<sha256(link 1 || line 2)>
+//	...
+//	head <last link, written when the file is rotated>
+//
+// Lines include their newline. The first file starts from a zero hash and
+// every rotated file continues from the head of the previous one, so editing,
+// removing or reordering lines or files breaks the chain. Anchoring the
+// heads elsewhere, e.g. in signed manifests, also detects a rewritten chain.
+
+// chainSuffix is the extension of hash chain sidecar files
+const chainSuffix = ".chain"
+
+// ChainError reports the first broken link of a hash chain
+type ChainError struct {
+	File   string // Log file containing the broken link
+	Line   int    // First line failing verification, or 0 for the whole file
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ChainError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("hash chain broken at %s: %s", e.File, e.Reason)
+	}
+	return fmt.Sprintf("hash chain broken at %s:%d: %s", e.File, e.Line, e.Reason)
+}
+
+// chainPath returns the sidecar path of a log file or backup
+func chainPath(path string) string {
+	return strings.TrimSuffix(path, ".gz") + chainSuffix
+}
+
+// chainLink returns the link of line in a chain continuing from prev
+func chainLink(prev [sha256.Size]byte, line []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(prev[:])
+	h.Write(line)
+
+	var link [sha256.Size]byte
+	h.Sum(link[:0])
+	return link
+}
+
+// chainFile is a parsed sidecar file
+type chainFile struct {
+	prev   [sha256.Size]byte
+	links  [][sha256.Size]byte
+	head   [sha256.Size]byte
+	sealed bool // Whether the sidecar ends with a head line
+}
+
+// last returns the last link of the chain, or prev if it has none
+func (cf *chainFile) last() [sha256.Size]byte {
+	if len(cf.links) == 0 {
+		return cf.prev
+	}
+	return cf.links[len(cf.links)-1]
+}
+
+// readChainFile parses a sidecar file
+func readChainFile(path string) (*chainFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cf := &chainFile{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if cf.sealed {
+			return nil, fmt.Errorf("%s:%d: unexpected line after head", path, n)
+		}
+
+		var dst *[sha256.Size]byte
+		switch {
+		case n == 1:
+			if !strings.HasPrefix(line, "prev ") {
+				return nil, fmt.Errorf("%s:%d: missing prev line", path, n)
+			}
+			dst, line = &cf.prev, strings.TrimPrefix(line, "prev ")
+		case strings.HasPrefix(line, "head "):
+			dst, line = &cf.head, strings.TrimPrefix(line, "head ")
+			cf.sealed = true
+		default:
+			cf.links = append(cf.links, [sha256.Size]byte{})
+			dst = &cf.links[len(cf.links)-1]
+		}
+
+		decoded, err := hex.DecodeString(line)
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("%s:%d: invalid hash %q", path, n, line)
+		}
+		copy(dst[:], decoded)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if cf.sealed && cf.head != cf.last() {
+		return nil, fmt.Errorf("%s: head does not match the last link", path)
+	}
+	return cf, nil
+}
+
+// hashChain appends the links of the lines written to a log file to its
+// sidecar
+type hashChain struct {
+	sidecar *os.File
+	head    [sha256.Size]byte // Last link
+	line    hash.Hash         // Hash of the pending line, seeded with head
+	pending bool              // Whether a partial line was written
+}
+
+// openHashChain opens the sidecar of the log file at path. A new sidecar
+// continues from prev; an existing one is resumed, linking the lines the log
+// file gained since the sidecar was last written, e.g. after a crash.
+func openHashChain(path string, prev [sha256.Size]byte) (*hashChain, error) {
+	sidecarPath := chainPath(path)
+
+	linked := 0
+	cf, err := readChainFile(sidecarPath)
+	switch {
+	case err == nil:
+		if cf.sealed {
+			return nil, fmt.Errorf("hash chain %s is already sealed", sidecarPath)
+		}
+		prev, linked = cf.last(), len(cf.links)
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.WriteFile(sidecarPath, []byte("prev "+hex.EncodeToString(prev[:])+"\n"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to create hash chain: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read hash chain: %w", err)
+	}
+
+	sidecar, err := os.OpenFile(sidecarPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash chain: %w", err)
+	}
+
+	c := &hashChain{sidecar: sidecar, head: prev, line: sha256.New()}
+	c.line.Write(c.head[:])
+
+	// Link the lines written after the last recorded link
+	file, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		sidecar.Close()
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	if err == nil {
+		defer file.Close()
+
+		reader := bufio.NewReader(file)
+		for n := 0; ; n++ {
+			line, err := reader.ReadBytes('\n')
+			if n >= linked && len(line) > 0 {
+				if werr := c.write(line); werr != nil {
+					sidecar.Close()
+					return nil, werr
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				sidecar.Close()
+				return nil, fmt.Errorf("failed to read log file: %w", err)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// write links the complete lines of p and keeps a trailing partial line
+// pending until its newline is written
+func (c *hashChain) write(p []byte) error {
+	var out []byte
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			c.line.Write(p)
+			c.pending = true
+			break
+		}
+		c.line.Write(p[:i+1])
+		out = c.next(out)
+		p = p[i+1:]
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+	if _, err := c.sidecar.Write(out); err != nil {
+		return fmt.Errorf("failed to write hash chain: %w", err)
+	}
+	return nil
+}
+
+// next completes the pending line and appends its link to out
+func (c *hashChain) next(out []byte) []byte {
+	c.line.Sum(c.head[:0])
+	c.line.Reset()
+	c.line.Write(c.head[:])
+	c.pending = false
+
+	out = hex.AppendEncode(out, c.head[:])
+	return append(out, '\n')
+}
+
+// seal links a pending partial line, records the head and closes the
+// sidecar. It is called when the log file is rotated.
+func (c *hashChain) seal() error {
+	var out []byte
+	if c.pending {
+		out = c.next(out)
+	}
+	out = append(out, "head "...)
+	out = hex.AppendEncode(out, c.head[:])
+	out = append(out, '\n')
+
+	if _, err := c.sidecar.Write(out); err != nil {
+		c.sidecar.Close()
+		return fmt.Errorf("failed to seal hash chain: %w", err)
+	}
+	return c.close()
+}
+
+// close closes the sidecar
+func (c *hashChain) close() error {
+	return c.sidecar.Close()
+}
+
+// backupChainHead returns the head the next log file continues from: the last
+// link of the newest backup, or a zero hash if there is none
+func (lr *LogRotator) backupChainHead() ([sha256.Size]byte, error) {
+	backups, err := lr.backups()
+	if err != nil || len(backups) == 0 {
+		return [sha256.Size]byte{}, err
+	}
+
+	cf, err := readChainFile(chainPath(backups[len(backups)-1].path))
+	if errors.Is(err, os.ErrNotExist) {
+		return [sha256.Size]byte{}, nil
+	}
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return cf.last(), nil
+}
+
+// Verify checks the hash chain of the log file at path and of its backups,
+// compressed or not, oldest first. It returns a *ChainError for the first
+// broken link, or nil if every line is intact. The chain of the oldest
+// remaining backup is trusted to start where it says, since the files it
+// continues from may have been removed by the retention limits.
+func Verify(path string) error {
+	backups, err := (&LogRotator{filePath: path}).backups()
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	files := make([]string, 0, len(backups)+1)
+	for _, backup := range backups {
+		files = append(files, backup.path)
+	}
+	if fileExists(path) {
+		files = append(files, path)
+	}
+
+	var head *[sha256.Size]byte
+	for i, file := range files {
+		last, err := verifyFile(file, head, i == len(files)-1 && file == path)
+		if err != nil {
+			return err
+		}
+		head = &last
+	}
+	return nil
+}
+
+// verifyFile checks the lines of a log file against its sidecar and returns
+// the last link. The chain must continue from head unless it is nil. The
+// active file may end with a partial line that is not linked yet.
+func verifyFile(path string, head *[sha256.Size]byte, active bool) ([sha256.Size]byte, error) {
+	var zero [sha256.Size]byte
+
+	cf, err := readChainFile(chainPath(path))
+	if err != nil {
+		return zero, &ChainError{File: path, Reason: fmt.Sprintf("unreadable hash chain: %v", err)}
+	}
+	if head != nil && cf.prev != *head {
+		return zero, &ChainError{File: path, Reason: "chain does not continue from the previous file"}
+	}
+	if !active && !cf.sealed {
+		return zero, &ChainError{File: path, Reason: "hash chain of a rotated file is not sealed"}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return zero, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return zero, &ChainError{File: path, Reason: fmt.Sprintf("unreadable compressed file: %v", err)}
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	reader := bufio.NewReader(r)
+	link := cf.prev
+	n := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if n == len(cf.links) {
+				if active && err == io.EOF && line[len(line)-1] != '\n' {
+					break // Partial line still being written
+				}
+				return zero, &ChainError{File: path, Line: n + 1, Reason: "line is not in the hash chain"}
+			}
+			link = chainLink(link, line)
+			if link != cf.links[n] {
+				return zero, &ChainError{File: path, Line: n + 1, Reason: "line does not match its link"}
+			}
+			n++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return zero, &ChainError{File: path, Line: n + 1, Reason: fmt.Sprintf("unreadable line: %v", err)}
+		}
+	}
+
+	if n < len(cf.links) {
+		return zero, &ChainError{File: path, Line: n + 1, Reason: fmt.Sprintf("%d linked lines are missing", len(cf.links)-n)}
+	}
+	return cf.last(), nil
+}
@@ -0,0 +1,215 @@
+package panlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newChainRotator creates a hash-chained rotator in a fresh directory
+func newChainRotator(t *testing.T, name string, compress bool) (*LogRotator, string) {
+	dir := filepath.Join("testdata", "chain", name)
+	os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	lr, err := NewLogRotator(LogRotatorConfig{
+		FilePath:   path,
+		MaxSize:    Unlimited,
+		MaxBackups: Unlimited,
+		Compress:   compress,
+		HashChain:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create log rotator: %v", err)
+	}
+	t.Cleanup(func() { lr.Close() })
+	return lr, path
+}
+
+// writeLines writes numbered lines to a rotator
+func writeLines(t *testing.T, lr *LogRotator, from, to int) {
+	for i := from; i < to; i++ {
+		if _, err := fmt.Fprintf(lr, "line %d\n", i); err != nil {
+			t.Fatalf("Failed to write line: %v", err)
+		}
+	}
+}
+
+// expectChainError checks that err is a *ChainError at file and line
+func expectChainError(t *testing.T, err error, file string, line int) {
+	t.Helper()
+	var cerr *ChainError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Expected *ChainError, got %v", err)
+	}
+	if cerr.File != file || cerr.Line != line {
+		t.Errorf("Expected broken link at %s:%d, got %v", file, line, err)
+	}
+}
+
+func TestHashChainAcrossRotations(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			lr, path := newChainRotator(t, fmt.Sprintf("rotations-%v", compress), compress)
+
+			writeLines(t, lr, 0, 5)
+			if err := lr.Rotate(); err != nil {
+				t.Fatalf("Failed to rotate: %v", err)
+			}
+			writeLines(t, lr, 5, 10)
+			if err := lr.Rotate(); err != nil {
+				t.Fatalf("Failed to rotate: %v", err)
+			}
+			writeLines(t, lr, 10, 12)
+
+			backups, err := lr.backups()
+			if err != nil || len(backups) != 2 {
+				t.Fatalf("Expected 2 backups without sidecars, got %v (%v)", backups, err)
+			}
+			for _, backup := range backups {
+				if !fileExists(chainPath(backup.path)) {
+					t.Errorf("Expected hash chain for %s", backup.path)
+				}
+			}
+
+			if err := Verify(path); err != nil {
+				t.Errorf("Expected intact chain, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHashChainTamperedLine(t *testing.T) {
+	lr, path := newChainRotator(t, "tampered", false)
+
+	writeLines(t, lr, 0, 5)
+	if err := lr.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	writeLines(t, lr, 5, 10)
+
+	backups, _ := lr.backups()
+	backup := backups[0].path
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if err := os.WriteFile(backup, bytes.Replace(data, []byte("line 3"), []byte("line X"), 1), 0644); err != nil {
+		t.Fatalf("Failed to tamper with backup: %v", err)
+	}
+	expectChainError(t, Verify(path), backup, 4)
+
+	// Restore the backup and remove a line from the active file instead
+	os.WriteFile(backup, data, 0644)
+	data, _ = os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte("line 6\n"), nil, 1), 0644)
+	expectChainError(t, Verify(path), path, 2)
+}
+
+func TestHashChainTamperedGzipBackup(t *testing.T) {
+	lr, path := newChainRotator(t, "gzip", true)
+
+	writeLines(t, lr, 0, 3)
+	if err := lr.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+
+	backups, _ := lr.backups()
+	backup := backups[0].path
+	if filepath.Ext(backup) != ".gz" {
+		t.Fatalf("Expected compressed backup, got %s", backup)
+	}
+
+	// Recompress the backup with its last line changed
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	io.WriteString(gw, "line 0\nline 1\nline 9\n")
+	gw.Close()
+	if err := os.WriteFile(backup, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to tamper with backup: %v", err)
+	}
+	expectChainError(t, Verify(path), backup, 3)
+}
+
+func TestHashChainRemovedBackup(t *testing.T) {
+	lr, path := newChainRotator(t, "removed", false)
+
+	for i := 0; i < 4; i++ {
+		writeLines(t, lr, i*2, i*2+2)
+		if err := lr.Rotate(); err != nil {
+			t.Fatalf("Failed to rotate: %v", err)
+		}
+	}
+
+	backups, _ := lr.backups()
+	// Removing the oldest backup is indistinguishable from retention
+	removeBackup(backups[0].path)
+	if err := Verify(path); err != nil {
+		t.Errorf("Expected chain to start at the oldest remaining backup, got %v", err)
+	}
+
+	removeBackup(backups[2].path)
+	expectChainError(t, Verify(path), backups[3].path, 0)
+}
+
+func TestHashChainResume(t *testing.T) {
+	lr, path := newChainRotator(t, "resume", false)
+
+	writeLines(t, lr, 0, 3)
+	if err := lr.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	writeLines(t, lr, 3, 5)
+	lr.Write([]byte("partial"))
+	lr.Close()
+
+	// Lines written without their links, as after a crash
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	file.WriteString(" line\nline 6\n")
+	file.Close()
+
+	lr, err = NewLogRotator(LogRotatorConfig{FilePath: path, MaxSize: Unlimited, HashChain: true})
+	if err != nil {
+		t.Fatalf("Failed to reopen log rotator: %v", err)
+	}
+	defer lr.Close()
+	writeLines(t, lr, 7, 9)
+
+	if err := Verify(path); err != nil {
+		t.Errorf("Expected resumed chain to be intact, got %v", err)
+	}
+	if err := lr.Reconfigure(LogRotatorConfig{FilePath: path}); err == nil {
+		t.Errorf("Expected error when disabling the hash chain")
+	}
+}
+
+func TestLoggerHashChain(t *testing.T) {
+	dir := filepath.Join("testdata", "chain", "logger")
+	os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+
+	logger, err := NewLogger(LoggerConfig{LogFile: path, MaxSize: 512, Format: "json", HashChain: true})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		logger.WithField("i", i).Info("Audited entry")
+	}
+	logger.Close()
+
+	backups, _ := (&LogRotator{filePath: path}).backups()
+	if len(backups) == 0 {
+		t.Fatalf("Expected the log file to be rotated")
+	}
+	if err := Verify(path); err != nil {
+		t.Errorf("Expected intact chain, got %v", err)
+	}
+}
@@ -0,0 +1,548 @@
+package panlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HTTP batch body encodings
+const (
+	HTTPJSONArray = "json_array" // A JSON array of entries
+	HTTPNDJSON    = "ndjson"     // Newline-delimited entries
+)
+
+// HTTPConfig configures an HTTP batch sink
+type HTTPConfig struct {
+	URL       string            // Ingestion endpoint
+	Method    string            // Request method (defaults to POST)
+	Encoding  string            // HTTPJSONArray (default) or HTTPNDJSON
+	Gzip      bool              // Whether to gzip request bodies
+	Headers   map[string]string // Extra request headers
+	AuthToken string            // Sent as "Authorization: Bearer <token>" when set
+
+	BatchSize    int           // Entries per request (defaults to 100)
+	BatchLatency time.Duration // Maximum time an entry waits for its batch (defaults to 1s)
+	MaxPending   int           // Batches waiting to be sent before new ones go to the dead-letter file (defaults to 10)
+
+	Timeout      time.Duration // Request timeout (defaults to 10s)
+	MaxRetries   int           // Retries of a failed request (defaults to 3, or Unlimited, NoRetries)
+	RetryBackoff time.Duration // First delay between retries, doubled each retry (defaults to 500ms)
+	MaxBackoff   time.Duration // Maximum delay between retries, including Retry-After delays (defaults to 30s)
+	CloseTimeout time.Duration // Time Close waits for pending batches before dead-lettering them (defaults to Timeout)
+
+	// File receiving the entries of batches that could not be delivered,
+	// one per line. Such entries are dropped when FilePath is empty.
+	DeadLetter LogRotatorConfig
+
+	Client *http.Client // Client used for requests (defaults to a client with Timeout)
+}
+
+// HTTPSink batches entries and sends them to an HTTP endpoint. Entries are
+// rendered by the sink's formatter, which must produce JSON for the
+// HTTPJSONArray encoding.
+//
+// Requests failing with a network error, a 5xx or a 429 status are retried
+// with exponential backoff, honoring Retry-After. Batches that still fail,
+// or that are rejected with another status, go to the dead-letter file.
+type HTTPSink struct {
+	config     HTTPConfig
+	deadLetter *LogRotator
+
+	// Text around the entries of HTTPJSONArray bodies
+	arrayPrefix, arraySuffix []byte
+
+	mu     sync.Mutex
+	batch  [][]byte
+	timer  *time.Timer
+	closed bool
+
+	batches chan [][]byte
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	// Cancels the requests of batches still pending when Close gives up
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	pending      atomic.Int64 // entries waiting to be sent
+	sent         atomic.Uint64
+	requests     atomic.Uint64
+	retries      atomic.Uint64
+	deadLettered atomic.Uint64
+	dropped      atomic.Uint64
+	lastStatus   atomic.Int64
+}
+
+// NewHTTPSink creates a sink posting batches to config.URL
+func NewHTTPSink(config HTTPConfig) (*HTTPSink, error) {
+	return newHTTPSink(config, "[", "]")
+}
+
+// newHTTPSink creates an HTTP sink whose HTTPJSONArray bodies are the
+// comma-separated entries between prefix and suffix
+func newHTTPSink(config HTTPConfig, prefix, suffix string) (*HTTPSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("HTTP sink URL is required")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.Encoding == "" {
+		config.Encoding = HTTPJSONArray
+	}
+	if config.Encoding != HTTPJSONArray && config.Encoding != HTTPNDJSON {
+		return nil, fmt.Errorf("unknown HTTP encoding %q", config.Encoding)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.BatchLatency <= 0 {
+		config.BatchLatency = time.Second
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 10
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	switch {
+	case config.MaxRetries == 0:
+		config.MaxRetries = 3
+	case config.MaxRetries == NoRetries:
+		config.MaxRetries = 0
+	case config.MaxRetries < 0 && config.MaxRetries != Unlimited:
+		return nil, fmt.Errorf("invalid HTTP max retries %d", config.MaxRetries)
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff < config.RetryBackoff {
+		config.MaxBackoff = config.RetryBackoff
+	}
+	if config.CloseTimeout <= 0 {
+		config.CloseTimeout = config.Timeout
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: config.Timeout}
+	}
+
+	s := &HTTPSink{
+		config:      config,
+		arrayPrefix: []byte(prefix),
+		arraySuffix: []byte(suffix),
+		batches:     make(chan [][]byte, config.MaxPending),
+		done:        make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	if config.DeadLetter.FilePath != "" {
+		deadLetter, err := NewLogRotator(config.DeadLetter)
+		if err != nil {
+			s.cancel()
+			return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+		}
+		s.deadLetter = deadLetter
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// WriteEntry implements Sink
+func (s *HTTPSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	record := bytes.TrimRight(p, "\n")
+	record = append(make([]byte, 0, len(record)), record...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("HTTP sink is closed")
+	}
+
+	s.batch = append(s.batch, record)
+	s.pending.Add(1)
+	if len(s.batch) >= s.config.BatchSize {
+		return s.flush()
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.config.BatchLatency, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if !s.closed {
+				s.flush()
+			}
+		})
+	}
+	return nil
+}
+
+// Close sends the pending batches and releases the sink. Batches that fail
+// while closing are not retried, and batches not sent within CloseTimeout
+// are abandoned; both go to the dead-letter file.
+func (s *HTTPSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	err := s.flush()
+	s.closed = true
+	close(s.done)
+	close(s.batches)
+	s.mu.Unlock()
+
+	deadline := time.AfterFunc(s.config.CloseTimeout, s.cancel)
+	s.wg.Wait()
+	if !deadline.Stop() && err == nil {
+		err = fmt.Errorf("pending batches not sent within %s", s.config.CloseTimeout)
+	}
+	s.cancel()
+
+	if s.deadLetter != nil {
+		if cerr := s.deadLetter.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// GetStats returns delivery statistics
+func (s *HTTPSink) GetStats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"url":           redactedURL(s.config.URL),
+		"pending":       s.pending.Load(),
+		"sent":          s.sent.Load(),
+		"requests":      s.requests.Load(),
+		"retries":       s.retries.Load(),
+		"dead_lettered": s.deadLettered.Load(),
+		"dropped":       s.dropped.Load(),
+	}
+	if status := s.lastStatus.Load(); status != 0 {
+		stats["last_status"] = status
+	}
+	return stats
+}
+
+// flush hands the current batch to the sender; the caller must hold s.mu
+func (s *HTTPSink) flush() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if len(s.batch) == 0 {
+		return nil
+	}
+
+	batch := s.batch
+	s.batch = nil
+
+	select {
+	case s.batches <- batch:
+		return nil
+	default:
+	}
+
+	// The endpoint does not keep up; keep the entries out of memory
+	s.pending.Add(-int64(len(batch)))
+	if err := s.writeDeadLetter(batch); err != nil {
+		return err
+	}
+	return fmt.Errorf("%d batches pending, %d entries moved to the dead-letter file", s.config.MaxPending, len(batch))
+}
+
+// run sends batches in order until the sink is closed
+func (s *HTTPSink) run() {
+	defer s.wg.Done()
+
+	for batch := range s.batches {
+		err := s.deliver(batch)
+		s.pending.Add(-int64(len(batch)))
+		if err == nil {
+			s.sent.Add(uint64(len(batch)))
+			continue
+		}
+		if derr := s.writeDeadLetter(batch); derr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to deliver batch to %s, %v; %v\n", s.config.URL, err, derr)
+		}
+	}
+}
+
+// deliver sends a batch, retrying failures that may be temporary
+func (s *HTTPSink) deliver(batch [][]byte) error {
+	body, err := s.encode(batch)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := s.send(body)
+		if err == nil {
+			return nil
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) {
+			return err
+		}
+		if s.config.MaxRetries != Unlimited && attempt >= s.config.MaxRetries {
+			return fmt.Errorf("giving up after %d retries: %w", attempt, err)
+		}
+
+		select {
+		case <-s.done:
+			return fmt.Errorf("sink closed while retrying: %w", err)
+		case <-time.After(s.retryDelay(attempt, retryAfter)):
+		}
+		s.retries.Add(1)
+	}
+}
+
+// retryDelay returns the delay before the retry following attempt: the
+// Retry-After delay if the endpoint sent one, or the backoff doubled once
+// per attempt, capped at MaxBackoff
+func (s *HTTPSink) retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, s.config.MaxBackoff)
+	}
+
+	delay := s.config.RetryBackoff
+	for i := 0; i < attempt && delay < s.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.config.MaxBackoff)
+}
+
+// retryableError marks a failure that may succeed when retried
+type retryableError struct {
+	err error
+}
+
+// Error implements the error interface
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// send performs one request and returns the delay requested by a
+// Retry-After header, if any
+func (s *HTTPSink) send(body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, s.config.Method, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.config.Encoding == HTTPNDJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.config.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.AuthToken)
+	}
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	s.requests.Add(1)
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return 0, &retryableError{fmt.Errorf("failed to send request: %w", err)}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	s.lastStatus.Store(int64(resp.StatusCode))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		err := &retryableError{fmt.Errorf("endpoint returned %s", resp.Status)}
+		return parseRetryAfter(resp.Header.Get("Retry-After")), err
+	default:
+		return 0, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+}
+
+// encode renders a batch as a request body
+func (s *HTTPSink) encode(batch [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gw *gzip.Writer
+	if s.config.Gzip {
+		gw = gzip.NewWriter(&buf)
+		w = gw
+	}
+
+	if s.config.Encoding == HTTPNDJSON {
+		for _, record := range batch {
+			w.Write(record)
+			w.Write([]byte("\n"))
+		}
+	} else {
+		w.Write(s.arrayPrefix)
+		w.Write(bytes.Join(batch, []byte(",")))
+		w.Write(s.arraySuffix)
+	}
+
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress batch: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeDeadLetter records undeliverable entries
+func (s *HTTPSink) writeDeadLetter(batch [][]byte) error {
+	if s.deadLetter == nil {
+		s.dropped.Add(uint64(len(batch)))
+		return fmt.Errorf("dropped %d entries", len(batch))
+	}
+
+	var buf bytes.Buffer
+	for _, record := range batch {
+		buf.Write(record)
+		buf.WriteByte('\n')
+	}
+	if _, err := s.deadLetter.Write(buf.Bytes()); err != nil {
+		s.dropped.Add(uint64(len(batch)))
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	s.deadLettered.Add(uint64(len(batch)))
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(t); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// withoutSecrets returns a copy of the configuration with the URL
+// password, auth token and header values masked, as headers often carry
+// API keys
+func (c HTTPConfig) withoutSecrets() HTTPConfig {
+	c.URL = redactedURL(c.URL)
+	if c.AuthToken != "" {
+		c.AuthToken = defaultMask
+	}
+	if c.Headers != nil {
+		headers := make(map[string]string, len(c.Headers))
+		for name := range c.Headers {
+			headers[name] = defaultMask
+		}
+		c.Headers = headers
+	}
+	return c
+}
+
+// redactedURL masks the password of a URL's userinfo
+func redactedURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return defaultMask
+	}
+	return u.Redacted()
+}
+
+// parseRetries parses a retry count, "unlimited" or "none"
+func parseRetries(value string) (int, error) {
+	switch {
+	case isKeyword([]byte(value), "unlimited"):
+		return Unlimited, nil
+	case isKeyword([]byte(value), "none"):
+		return NoRetries, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(value))
+}
+
+// httpConfigFromOptions applies string options from config files. Headers
+// are given as "header.<Name>".
+func httpConfigFromOptions(config HTTPConfig, options map[string]string) (HTTPConfig, error) {
+	for key, value := range options {
+		var err error
+		switch {
+		case strings.HasPrefix(key, "header."):
+			headers := make(map[string]string, len(config.Headers)+1)
+			for name, v := range config.Headers {
+				headers[name] = v
+			}
+			headers[strings.TrimPrefix(key, "header.")] = value
+			config.Headers = headers
+		case key == "url":
+			config.URL = value
+		case key == "method":
+			config.Method = value
+		case key == "encoding":
+			config.Encoding = value
+		case key == "gzip":
+			config.Gzip, err = strconv.ParseBool(value)
+		case key == "auth_token":
+			config.AuthToken = value
+		case key == "batch_size":
+			config.BatchSize, err = strconv.Atoi(value)
+		case key == "batch_latency":
+			config.BatchLatency, err = ParseDuration(value)
+		case key == "max_pending":
+			config.MaxPending, err = strconv.Atoi(value)
+		case key == "timeout":
+			config.Timeout, err = ParseDuration(value)
+		case key == "max_retries":
+			config.MaxRetries, err = parseRetries(value)
+		case key == "retry_backoff":
+			config.RetryBackoff, err = ParseDuration(value)
+		case key == "max_backoff":
+			config.MaxBackoff, err = ParseDuration(value)
+		case key == "close_timeout":
+			config.CloseTimeout, err = ParseDuration(value)
+		case key == "dead_letter_path":
+			config.DeadLetter.FilePath = value
+		default:
+			err = fmt.Errorf("unknown option")
+		}
+		if err != nil {
+			return config, fmt.Errorf("http option %s: %w", key, err)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,305 @@
+package panlog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeIngest is an httptest endpoint recording request bodies and replying
+// with a scripted sequence of statuses
+type fakeIngest struct {
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   chan []byte
+}
+
+func newFakeIngest(t *testing.T, statuses ...int) (*fakeIngest, *httptest.Server) {
+	f := &fakeIngest{statuses: statuses, bodies: make(chan []byte, 16)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("Failed to read gzip body: %v", err)
+				return
+			}
+			body = gr
+		}
+		data, _ := io.ReadAll(body)
+
+		f.mu.Lock()
+		f.headers = append(f.headers, r.Header.Clone())
+		status := http.StatusOK
+		if len(f.statuses) > 0 {
+			status, f.statuses = f.statuses[0], f.statuses[1:]
+		}
+		f.mu.Unlock()
+
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+		if status < 300 {
+			f.bodies <- data
+		}
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+// receiveBody waits for the next accepted request body
+func (f *fakeIngest) receiveBody(t *testing.T) []byte {
+	select {
+	case body := <-f.bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for request")
+		return nil
+	}
+}
+
+func TestHTTPSinkJSONArray(t *testing.T) {
+	ingest, server := newFakeIngest(t)
+
+	logger, err := NewLogger(LoggerConfig{
+		Sinks: []SinkConfig{{
+			Type:   "http",
+			Format: "json",
+			HTTP: HTTPConfig{
+				URL:       server.URL,
+				Gzip:      true,
+				AuthToken: "secret",
+				Headers:   map[string]string{"X-Source": "panlog"},
+				BatchSize: 3,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	for _, msg := range []string{"one", "two", "three"} {
+		logger.Info(msg)
+	}
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(ingest.receiveBody(t), &entries); err != nil {
+		t.Fatalf("Failed to parse JSON array body: %v", err)
+	}
+	if len(entries) != 3 || entries[0]["msg"] != "one" || entries[2]["msg"] != "three" {
+		t.Errorf("Expected batch of 3 entries in order, got %v", entries)
+	}
+
+	ingest.mu.Lock()
+	header := ingest.headers[0]
+	ingest.mu.Unlock()
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Expected bearer token, got %q", header.Get("Authorization"))
+	}
+	if header.Get("X-Source") != "panlog" {
+		t.Errorf("Expected custom header, got %q", header.Get("X-Source"))
+	}
+	if header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", header.Get("Content-Type"))
+	}
+}
+
+func TestHTTPSinkNDJSONLatency(t *testing.T) {
+	ingest, server := newFakeIngest(t)
+
+	sink, err := NewHTTPSink(HTTPConfig{URL: server.URL, Encoding: HTTPNDJSON, BatchLatency: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create HTTP sink: %v", err)
+	}
+	defer sink.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	sink.WriteEntry(entry, []byte("{\"msg\":\"a\"}\n"))
+	sink.WriteEntry(entry, []byte("{\"msg\":\"b\"}\n"))
+
+	// The batch is not full, so it is sent once the latency expires
+	if body := string(ingest.receiveBody(t)); body != "{\"msg\":\"a\"}\n{\"msg\":\"b\"}\n" {
+		t.Errorf("Expected NDJSON body, got %q", body)
+	}
+}
+
+func TestHTTPSinkRetry(t *testing.T) {
+	ingest, server := newFakeIngest(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	sink, err := NewHTTPSink(HTTPConfig{URL: server.URL, BatchSize: 1, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create HTTP sink: %v", err)
+	}
+	defer sink.Close()
+
+	sink.WriteEntry(logrus.NewEntry(logrus.New()), []byte(`{"msg":"retried"}`))
+	if body := string(ingest.receiveBody(t)); body != `[{"msg":"retried"}]` {
+		t.Errorf("Expected batch after retries, got %q", body)
+	}
+
+	stats := sink.GetStats()
+	if stats["retries"] != uint64(2) || stats["requests"] != uint64(3) {
+		t.Errorf("Expected 2 retries in 3 requests, got %v", stats)
+	}
+}
+
+func TestHTTPSinkDeadLetter(t *testing.T) {
+	_, server := newFakeIngest(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusBadRequest)
+	deadLetterPath := filepath.Join("testdata", "http_dead_letter.log")
+	os.Remove(deadLetterPath)
+
+	sink, err := NewHTTPSink(HTTPConfig{
+		URL:          server.URL,
+		BatchSize:    1,
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+		DeadLetter:   LogRotatorConfig{FilePath: deadLetterPath},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create HTTP sink: %v", err)
+	}
+
+	entry := logrus.NewEntry(logrus.New())
+	sink.WriteEntry(entry, []byte("{\"msg\":\"exhausted\"}\n"))
+	sink.WriteEntry(entry, []byte("{\"msg\":\"rejected\"}\n"))
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Failed to close HTTP sink: %v", err)
+	}
+
+	f, err := os.Open(deadLetterPath)
+	if err != nil {
+		t.Fatalf("Failed to open dead-letter file: %v", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if strings.Join(lines, "|") != `{"msg":"exhausted"}|{"msg":"rejected"}` {
+		t.Errorf("Expected both entries in the dead-letter file, got %v", lines)
+	}
+	if dead := sink.GetStats()["dead_lettered"]; dead != uint64(2) {
+		t.Errorf("Expected 2 dead-lettered entries, got %v", dead)
+	}
+}
+
+func TestHTTPSinkNoRetries(t *testing.T) {
+	_, server := newFakeIngest(t, http.StatusServiceUnavailable)
+
+	config, err := httpConfigFromOptions(HTTPConfig{}, map[string]string{"url": server.URL, "batch_size": "1", "max_retries": "none"})
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	sink, err := NewHTTPSink(config)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP sink: %v", err)
+	}
+
+	sink.WriteEntry(logrus.NewEntry(logrus.New()), []byte(`{"msg":"once"}`))
+	sink.Close()
+
+	stats := sink.GetStats()
+	if stats["requests"] != uint64(1) || stats["retries"] != uint64(0) || stats["dropped"] != uint64(1) {
+		t.Errorf("Expected a single request without retries, got %v", stats)
+	}
+
+	if _, err := NewHTTPSink(HTTPConfig{URL: server.URL, MaxRetries: -5}); err == nil {
+		t.Error("Expected invalid max retries to be rejected")
+	}
+}
+
+func TestHTTPSinkCloseTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request context ends with the connection once the body is read
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	deadLetterPath := filepath.Join("testdata", "http_close_timeout.log")
+	os.Remove(deadLetterPath)
+
+	config, err := httpConfigFromOptions(HTTPConfig{}, map[string]string{"url": server.URL, "close_timeout": "100ms"})
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	config.BatchSize = 1
+	config.Timeout = time.Minute
+	config.DeadLetter = LogRotatorConfig{FilePath: deadLetterPath}
+	sink, err := NewHTTPSink(config)
+	if err != nil {
+		t.Fatalf("Failed to create HTTP sink: %v", err)
+	}
+
+	entry := logrus.NewEntry(logrus.New())
+	sink.WriteEntry(entry, []byte("{\"msg\":\"stuck\"}\n"))
+	sink.WriteEntry(entry, []byte("{\"msg\":\"queued\"}\n"))
+
+	start := time.Now()
+	if err := sink.Close(); err == nil {
+		t.Error("Expected an error for batches not sent before the close timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Close to give up after the close timeout, took %v", elapsed)
+	}
+
+	content, err := os.ReadFile(deadLetterPath)
+	if err != nil {
+		t.Fatalf("Failed to read dead-letter file: %v", err)
+	}
+	if string(content) != "{\"msg\":\"stuck\"}\n{\"msg\":\"queued\"}\n" {
+		t.Errorf("Expected both entries in the dead-letter file, got %q", content)
+	}
+}
+
+func TestHTTPSinkRetryDelay(t *testing.T) {
+	sink, err := NewHTTPSink(HTTPConfig{URL: "http://localhost", RetryBackoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create HTTP sink: %v", err)
+	}
+	defer sink.Close()
+
+	if delay := sink.retryDelay(2, 0); delay != 2*time.Second {
+		t.Errorf("Expected 2s after 2 attempts, got %v", delay)
+	}
+	// Long outages must neither overflow the backoff nor exceed the cap
+	for _, attempt := range []int{5, 35, 1000} {
+		if delay := sink.retryDelay(attempt, 0); delay != 10*time.Second {
+			t.Errorf("Expected backoff capped at 10s after %d attempts, got %v", attempt, delay)
+		}
+	}
+	if delay := sink.retryDelay(0, time.Hour); delay != 10*time.Second {
+		t.Errorf("Expected Retry-After capped at 10s, got %v", delay)
+	}
+	if delay := sink.retryDelay(0, 3*time.Second); delay != 3*time.Second {
+		t.Errorf("Expected Retry-After of 3s, got %v", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if delay := parseRetryAfter("3"); delay != 3*time.Second {
+		t.Errorf("Expected 3s, got %v", delay)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay := parseRetryAfter(date); delay <= 0 || delay > time.Minute {
+		t.Errorf("Expected delay up to a minute, got %v", delay)
+	}
+	if delay := parseRetryAfter("soon"); delay != 0 {
+		t.Errorf("Expected no delay for invalid value, got %v", delay)
+	}
+}
@@ -0,0 +1,227 @@
+package panlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultJournalSocket is the socket of the systemd journal's native protocol
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// JournaldConfig configures a journald sink
+type JournaldConfig struct {
+	SocketPath string // Journal socket (defaults to DefaultJournalSocket)
+	Identifier string // SYSLOG_IDENTIFIER (defaults to the program name)
+}
+
+// JournaldSink writes entries to the systemd journal using its native
+// protocol. The entry message becomes MESSAGE, the level becomes PRIORITY
+// and every entry field becomes an uppercase journal field, so the sink's
+// formatter is not used. Entries too large for a datagram are passed in a
+// sealed memfd.
+type JournaldSink struct {
+	config JournaldConfig
+	addr   *net.UnixAddr
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// NewJournaldSink creates a sink writing to the journal socket
+func NewJournaldSink(config JournaldConfig) (*JournaldSink, error) {
+	if config.SocketPath == "" {
+		config.SocketPath = DefaultJournalSocket
+	}
+	if config.Identifier == "" {
+		config.Identifier = filepath.Base(os.Args[0])
+	}
+
+	if _, err := os.Stat(config.SocketPath); err != nil {
+		return nil, fmt.Errorf("journal socket not available: %w", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal socket: %w", err)
+	}
+
+	return &JournaldSink{
+		config: config,
+		addr:   &net.UnixAddr{Name: config.SocketPath, Net: "unixgram"},
+		conn:   conn,
+	}, nil
+}
+
+// WriteEntry implements Sink
+func (s *JournaldSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	payload := s.encode(entry)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return fmt.Errorf("journald sink is closed")
+	}
+
+	_, _, err := s.conn.WriteMsgUnix(payload, nil, s.addr)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		err = sendJournalMemfd(s.conn, s.addr, payload)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write to journal: %w", err)
+	}
+	return nil
+}
+
+// Close implements Sink
+func (s *JournaldSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// GetStats returns the sink's settings and state
+func (s *JournaldSink) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"socket_path": s.config.SocketPath,
+		"identifier":  s.config.Identifier,
+		"connected":   s.conn != nil,
+	}
+}
+
+// encode renders an entry in the journal's native protocol
+func (s *JournaldSink) encode(entry *logrus.Entry) []byte {
+	var buf bytes.Buffer
+
+	writeJournalField(&buf, "MESSAGE", entry.Message)
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Level)))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", s.config.Identifier)
+	if entry.HasCaller() {
+		writeJournalField(&buf, "CODE_FILE", entry.Caller.File)
+		writeJournalField(&buf, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+		writeJournalField(&buf, "CODE_FUNC", entry.Caller.Function)
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := entry.Data[key]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		writeJournalField(&buf, journalFieldName(key), fmt.Sprint(value))
+	}
+
+	return buf.Bytes()
+}
+
+// writeJournalField appends a field, using the binary form for values that
+// contain newlines
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalReservedFields are the fields written by the sink itself, which
+// entry fields must not duplicate or override
+var journalReservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// journalFieldName makes a field name a valid journal field name: uppercase
+// letters, digits and underscores, not starting with an underscore or digit
+// (those are reserved for trusted fields), at most 64 characters. Names of
+// fields written by the sink itself get an "F_" prefix.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+
+	trimmed := strings.TrimLeft(string(name), "_")
+	if trimmed == "" || (trimmed[0] >= '0' && trimmed[0] <= '9') || journalReservedFields[trimmed] {
+		trimmed = "F_" + trimmed
+	}
+	if len(trimmed) > 64 {
+		trimmed = trimmed[:64]
+	}
+	return trimmed
+}
+
+// JournaldDetected reports whether the process runs under systemd with its
+// standard output or error connected to the journal, as announced by the
+// JOURNAL_STREAM environment variable
+func JournaldDetected() bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" {
+		return false
+	}
+
+	dev, ino, ok := strings.Cut(stream, ":")
+	if !ok {
+		return false
+	}
+	for _, f := range []*os.File{os.Stderr, os.Stdout} {
+		if fileDevIno(f) == dev+":"+ino {
+			return true
+		}
+	}
+	return false
+}
+
+// journaldConfigFromOptions applies string options from config files
+func journaldConfigFromOptions(config JournaldConfig, options map[string]string) (JournaldConfig, error) {
+	for key, value := range options {
+		switch key {
+		case "socket_path":
+			config.SocketPath = value
+		case "identifier":
+			config.Identifier = value
+		default:
+			return config, fmt.Errorf("journald option %s: unknown option", key)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,46 @@
+package panlog
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendJournalMemfd passes a payload that does not fit in a datagram to the
+// journal as a sealed memfd
+func sendJournalMemfd(conn *net.UnixConn, addr *net.UnixAddr, payload []byte) error {
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_ALLOW_SEALING|unix.MFD_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("failed to create memfd: %w", err)
+	}
+	file := os.NewFile(uintptr(fd), "journal-entry")
+	defer file.Close()
+
+	if _, err := file.Write(payload); err != nil {
+		return fmt.Errorf("failed to write memfd: %w", err)
+	}
+
+	seals := unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, seals); err != nil {
+		return fmt.Errorf("failed to seal memfd: %w", err)
+	}
+
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(fd), addr)
+	return err
+}
+
+// fileDevIno returns the "device:inode" of f as used by JOURNAL_STREAM
+func fileDevIno(f *os.File) string {
+	stat, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", sys.Dev, sys.Ino)
+}
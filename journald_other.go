@@ -0,0 +1,19 @@
+//go:build !linux
+
+package panlog
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// sendJournalMemfd is only supported on Linux, where the journal runs
+func sendJournalMemfd(conn *net.UnixConn, addr *net.UnixAddr, payload []byte) error {
+	return fmt.Errorf("entry of %d bytes is too large for the journal socket", len(payload))
+}
+
+// fileDevIno is only supported on Linux, where the journal runs
+func fileDevIno(f *os.File) string {
+	return ""
+}
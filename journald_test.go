@@ -0,0 +1,236 @@
+//go:build linux
+
+package panlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeJournal is a unix datagram stand-in for the journal socket that
+// decodes received entries, including entries passed in a memfd
+func newFakeJournal(t *testing.T) (string, chan map[string]string) {
+	dir, err := os.MkdirTemp("", "journal")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	path := filepath.Join(dir, "socket")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", path, err)
+	}
+	if err := conn.SetReadBuffer(4 * 1024 * 1024); err != nil {
+		t.Fatalf("Failed to set read buffer: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	})
+
+	entries := make(chan map[string]string, 16)
+	go func() {
+		buf := make([]byte, 64*1024)
+		oob := make([]byte, 1024)
+		for {
+			n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+			if err != nil {
+				return
+			}
+
+			payload := append([]byte(nil), buf[:n]...)
+			if oobn > 0 {
+				payload, err = readJournalMemfd(oob[:oobn])
+				if err != nil {
+					t.Errorf("Failed to read memfd: %v", err)
+					continue
+				}
+			}
+
+			fields, err := decodeJournalEntry(payload)
+			if err != nil {
+				t.Errorf("Failed to decode entry: %v", err)
+				continue
+			}
+			entries <- fields
+		}
+	}()
+	return path, entries
+}
+
+// readJournalMemfd reads the payload of a file descriptor passed with an entry
+func readJournalMemfd(oob []byte) ([]byte, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil || len(msgs) != 1 {
+		return nil, fmt.Errorf("unexpected control messages: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		return nil, fmt.Errorf("unexpected unix rights: %v", err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "memfd")
+	defer f.Close()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// decodeJournalEntry parses the journal's native protocol
+func decodeJournalEntry(payload []byte) (map[string]string, error) {
+	fields := make(map[string]string)
+	for len(payload) > 0 {
+		end := bytes.IndexByte(payload, '\n')
+		if end < 0 {
+			return nil, errors.New("missing newline")
+		}
+		line := payload[:end]
+		payload = payload[end+1:]
+
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			continue
+		}
+
+		if len(payload) < 8 {
+			return nil, errors.New("truncated binary field")
+		}
+		size := binary.LittleEndian.Uint64(payload)
+		payload = payload[8:]
+		if uint64(len(payload)) < size+1 || payload[size] != '\n' {
+			return nil, errors.New("invalid binary field")
+		}
+		fields[string(line)] = string(payload[:size])
+		payload = payload[size+1:]
+	}
+	return fields, nil
+}
+
+// receiveJournal waits for the next entry of the fake journal
+func receiveJournal(t *testing.T, entries chan map[string]string) map[string]string {
+	select {
+	case fields := <-entries:
+		return fields
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for journal entry")
+		return nil
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	path, entries := newFakeJournal(t)
+
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel: "debug",
+		Sinks: []SinkConfig{
+			{Type: "journald", Journald: JournaldConfig{SocketPath: path, Identifier: "panlog-test"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WithFields(logrus.Fields{
+		"request-id": "abc",
+		"error":      errors.New("boom"),
+		"stack":      "line one\nline two",
+		"_private":   1,
+		"priority":   0,
+		"message":    "spoofed",
+	}).Warn("Warning message")
+
+	fields := receiveJournal(t, entries)
+	expected := map[string]string{
+		"MESSAGE":           "Warning message",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "panlog-test",
+		"REQUEST_ID":        "abc",
+		"ERROR":             "boom",
+		"STACK":             "line one\nline two",
+		"PRIVATE":           "1",
+		"F_PRIORITY":        "0",
+		"F_MESSAGE":         "spoofed",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, fields[key])
+		}
+	}
+
+	logger.Debug("Debug message")
+	if fields := receiveJournal(t, entries); fields["PRIORITY"] != "7" {
+		t.Errorf("Expected PRIORITY=7 for debug, got %q", fields["PRIORITY"])
+	}
+}
+
+func TestJournaldSinkLargeEntry(t *testing.T) {
+	path, entries := newFakeJournal(t)
+
+	sink, err := NewJournaldSink(JournaldConfig{SocketPath: path})
+	if err != nil {
+		t.Fatalf("Failed to create journald sink: %v", err)
+	}
+	defer sink.Close()
+
+	message := strings.Repeat("x", 1024*1024)
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.InfoLevel
+	entry.Message = message
+	if err := sink.WriteEntry(entry, nil); err != nil {
+		t.Fatalf("Failed to write large entry: %v", err)
+	}
+
+	fields := receiveJournal(t, entries)
+	if fields["MESSAGE"] != message {
+		t.Errorf("Expected %d byte message, got %d bytes", len(message), len(fields["MESSAGE"]))
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":   "USER_ID",
+		"http.path": "HTTP_PATH",
+		"_hidden":   "HIDDEN",
+		"1st":       "F_1ST",
+		"__":        "F_",
+		"message":   "F_MESSAGE",
+		"Priority":  "F_PRIORITY",
+		"code_line": "F_CODE_LINE",
+	}
+	for key, expected := range tests {
+		if name := journalFieldName(key); name != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, key, name)
+		}
+	}
+}
+
+func TestJournaldDetected(t *testing.T) {
+	t.Setenv("JOURNAL_STREAM", "")
+	if JournaldDetected() {
+		t.Errorf("Expected no journal without JOURNAL_STREAM")
+	}
+
+	t.Setenv("JOURNAL_STREAM", fileDevIno(os.Stderr))
+	if !JournaldDetected() {
+		t.Errorf("Expected journal when JOURNAL_STREAM matches stderr")
+	}
+
+	t.Setenv("JOURNAL_STREAM", "0:0")
+	if JournaldDetected() {
+		t.Errorf("Expected no journal when JOURNAL_STREAM does not match")
+	}
+}
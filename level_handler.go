@@ -0,0 +1,219 @@
+package panlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// levelOverride is a temporary level change that reverts after a TTL
+type levelOverride struct {
+	previous    logrus.Level
+	hadPrevious bool // false if a module level did not exist before
+	expires     time.Time
+	timer       *time.Timer
+}
+
+// SetLevelFor changes the logger's level for ttl, after which the level that
+// was active before the first temporary change is restored. A ttl of zero
+// makes the change permanent and cancels any pending revert.
+func (l *Logger) SetLevelFor(level logrus.Level, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setLevelFor("", level, ttl)
+}
+
+// SetModuleLevelFor is like SetLevelFor for the modules matching pattern
+func (l *Logger) SetModuleLevelFor(pattern string, level logrus.Level, ttl time.Duration) error {
+	if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+		return fmt.Errorf("invalid module pattern %q", pattern)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.setLevelFor(pattern, level, ttl)
+	return nil
+}
+
+// setLevelFor applies a level to the logger (empty pattern) or to a module
+// pattern and schedules the revert; the caller must hold l.mu
+func (l *Logger) setLevelFor(pattern string, level logrus.Level, ttl time.Duration) {
+	override := &levelOverride{expires: time.Now().Add(ttl)}
+	if previous, ok := l.overrides[pattern]; ok {
+		previous.timer.Stop()
+		override.previous, override.hadPrevious = previous.previous, previous.hadPrevious
+		delete(l.overrides, pattern)
+	} else if pattern == "" {
+		override.previous, override.hadPrevious = l.GetLevel(), true
+	} else {
+		override.previous, override.hadPrevious = l.moduleLevels[pattern]
+	}
+
+	l.applyLevel(pattern, level, true)
+	if ttl <= 0 {
+		return
+	}
+
+	override.timer = time.AfterFunc(ttl, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.overrides[pattern] == override {
+			delete(l.overrides, pattern)
+			l.applyLevel(pattern, override.previous, override.hadPrevious)
+		}
+	})
+	l.overrides[pattern] = override
+}
+
+// applyLevel sets or, if set is false, removes a module level; the empty
+// pattern stands for the logger itself. The caller must hold l.mu.
+func (l *Logger) applyLevel(pattern string, level logrus.Level, set bool) {
+	switch {
+	case pattern == "":
+		l.setLevel(level)
+		return
+	case set:
+		l.moduleLevels[pattern] = level
+	default:
+		delete(l.moduleLevels, pattern)
+	}
+	l.refreshModules()
+}
+
+// cancelOverride stops a pending level revert for pattern; the caller must
+// hold l.mu
+func (l *Logger) cancelOverride(pattern string) {
+	if override, ok := l.overrides[pattern]; ok {
+		override.timer.Stop()
+		delete(l.overrides, pattern)
+	}
+}
+
+// cancelOverrides stops every pending level revert; the caller must hold l.mu
+func (l *Logger) cancelOverrides() {
+	for pattern := range l.overrides {
+		l.cancelOverride(pattern)
+	}
+}
+
+// levelState is the JSON representation of a level
+type levelState struct {
+	Level    string     `json:"level"`
+	RevertTo string     `json:"revert_to,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+// levelsState is the JSON representation of the logger's and module levels
+type levelsState struct {
+	levelState
+	Modules map[string]levelState `json:"modules,omitempty"`
+}
+
+// levelRequest is the JSON body accepted when changing a level
+type levelRequest struct {
+	Module string `json:"module,omitempty"`
+	Level  string `json:"level"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+// LevelHandler returns an http.Handler that reports the logger's level and
+// module levels on GET and changes them on PUT or POST with a JSON body such as
+//
+//	{"level": "debug", "ttl": "10m"}
+//	{"module": "http.*", "level": "debug"}
+//
+// With a ttl the level reverts automatically once it expires. An empty level
+// for a module removes its module level.
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var req levelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeLevelError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+				return
+			}
+			if err := l.applyLevelRequest(req); err != nil {
+				writeLevelError(w, http.StatusBadRequest, err)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.levelsState())
+	})
+}
+
+// applyLevelRequest validates and applies a level change request
+func (l *Logger) applyLevelRequest(req levelRequest) error {
+	if req.Module != "" && req.Level == "" {
+		l.ClearModuleLevel(req.Module)
+		return nil
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		ttl, err = ParseDuration(req.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid ttl: %w", err)
+		}
+	}
+
+	if req.Module != "" {
+		return l.SetModuleLevelFor(req.Module, level, ttl)
+	}
+	l.SetLevelFor(level, ttl)
+	return nil
+}
+
+// levelsState returns the current levels and any pending reverts
+func (l *Logger) levelsState() levelsState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state := levelsState{levelState: l.levelState("", l.GetLevel())}
+	if len(l.moduleLevels) > 0 {
+		state.Modules = make(map[string]levelState, len(l.moduleLevels))
+		for pattern, level := range l.moduleLevels {
+			state.Modules[pattern] = l.levelState(pattern, level)
+		}
+	}
+	return state
+}
+
+// levelState describes the level of pattern; the caller must hold l.mu
+func (l *Logger) levelState(pattern string, level logrus.Level) levelState {
+	state := levelState{Level: level.String()}
+	if override, ok := l.overrides[pattern]; ok && override.timer != nil {
+		expires := override.expires
+		state.Expires = &expires
+		if override.hadPrevious {
+			state.RevertTo = override.previous.String()
+		}
+	}
+	return state
+}
+
+// writeLevelError writes err as a JSON error response
+func writeLevelError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
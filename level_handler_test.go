@@ -0,0 +1,166 @@
+package panlog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLevelHandler(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel: "info",
+		LogFile:  "testdata/level_handler_test.log",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	server := httptest.NewServer(logger.LevelHandler())
+	defer server.Close()
+
+	// Report the current level
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	var state levelState
+	json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if state.Level != "info" {
+		t.Errorf("Expected level info, got %q", state.Level)
+	}
+
+	// Change the level temporarily
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"level": "debug", "ttl": "50ms"}`))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT failed: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&state)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || state.Level != "debug" || state.RevertTo != "info" || state.Expires == nil {
+		t.Errorf("Unexpected response %d: %+v", resp.StatusCode, state)
+	}
+	if logger.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected debug level, got %v", logger.GetLevel())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for logger.GetLevel() != logrus.InfoLevel {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the level to revert")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Reject invalid requests
+	for _, body := range []string{`{"level": "loud"}`, `{"level": "debug", "ttl": "soon"}`, `not json`} {
+		req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, server.URL, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", resp.StatusCode)
+	}
+}
+
+func TestSetLevelForKeepsOriginalLevel(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{LogLevel: "warn"})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.SetLevelFor(logrus.InfoLevel, time.Hour)
+	logger.SetLevelFor(logrus.DebugLevel, time.Hour)
+
+	if state := logger.levelsState(); state.RevertTo != "warning" {
+		t.Errorf("Expected revert to the original level, got %q", state.RevertTo)
+	}
+
+	logger.SetLevelFor(logrus.ErrorLevel, 0)
+	if state := logger.levelsState(); state.Expires != nil {
+		t.Errorf("Expected a permanent change to cancel the revert, got %+v", state)
+	}
+}
+
+func TestSetLevelCancelsRevert(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{LogLevel: "warn"})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.SetLevelFor(logrus.DebugLevel, 50*time.Millisecond)
+	logger.SetLevel(logrus.ErrorLevel)
+	time.Sleep(150 * time.Millisecond)
+
+	if level := logger.GetLevel(); level != logrus.ErrorLevel {
+		t.Errorf("Expected SetLevel to survive the expired override, got %s", level)
+	}
+	if state := logger.levelsState(); state.Expires != nil {
+		t.Errorf("Expected SetLevel to cancel the revert, got %+v", state)
+	}
+}
+
+func TestLevelHandlerModules(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel:     "info",
+		ModuleLevels: map[string]string{"db": "warn"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	handler := logger.LevelHandler()
+	put := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := put(`{"module": "db", "level": "debug", "ttl": "1h"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var state levelsState
+	json.NewDecoder(rec.Body).Decode(&state)
+	if db := state.Modules["db"]; db.Level != "debug" || db.RevertTo != "warning" {
+		t.Errorf("Unexpected db state: %+v", db)
+	}
+	if logger.Module("db").GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected db at debug, got %v", logger.Module("db").GetLevel())
+	}
+
+	if rec := put(`{"module": "db", "level": ""}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if logger.Module("db").GetLevel() != logrus.InfoLevel {
+		t.Errorf("Expected db to inherit info, got %v", logger.Module("db").GetLevel())
+	}
+
+	if rec := put(`{"module": "[", "level": "debug"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid pattern, got %d", rec.Code)
+	}
+}
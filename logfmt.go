@@ -0,0 +1,141 @@
+package panlog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogfmtFormatter renders entries as logfmt key=value lines. Keys are written
+// in a deterministic order: time, level and msg, then PriorityKeys in the
+// given order, then the remaining fields sorted by name. Values are quoted
+// only when needed, with Go escaping.
+type LogfmtFormatter struct {
+	TimestampFormat string   // Layout of the time key (defaults to time.RFC3339)
+	PriorityKeys    []string // Fields written right after msg, e.g. "module", "request_id"
+	Colors          bool     // Whether to color the level; only for terminals
+}
+
+// logfmtReserved are the keys written by the formatter itself
+var logfmtReserved = map[string]bool{"time": true, "level": true, "msg": true}
+
+// Format implements logrus.Formatter
+func (f *LogfmtFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	layout := f.TimestampFormat
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	data := make(logrus.Fields, len(entry.Data)+2)
+	for key, value := range entry.Data {
+		if logfmtReserved[key] {
+			key = "fields." + key
+		}
+		data[key] = value
+	}
+	if entry.HasCaller() {
+		data["func"] = entry.Caller.Function
+		data["file"] = fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+	}
+
+	var buf bytes.Buffer
+	writeLogfmtPair(&buf, "time", entry.Time.Format(layout))
+	buf.WriteString(" level=")
+	if f.Colors {
+		fmt.Fprintf(&buf, "\x1b[%dm%s\x1b[0m", logfmtLevelColor(entry.Level), entry.Level.String())
+	} else {
+		buf.WriteString(entry.Level.String())
+	}
+	buf.WriteByte(' ')
+	writeLogfmtPair(&buf, "msg", entry.Message)
+
+	for _, key := range f.PriorityKeys {
+		if value, ok := data[key]; ok {
+			buf.WriteByte(' ')
+			writeLogfmtPair(&buf, key, value)
+			delete(data, key)
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buf.WriteByte(' ')
+		writeLogfmtPair(&buf, key, data[key])
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// writeLogfmtPair writes key=value
+func writeLogfmtPair(buf *bytes.Buffer, key string, value interface{}) {
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(value))
+}
+
+// logfmtKey replaces the characters that cannot appear in a key
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	out := []rune(key)
+	for i, r := range out {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+// logfmtValue renders a value, quoting it if it is empty or contains
+// spaces, '=', quotes or unprintable characters
+func logfmtValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		s = v
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	default:
+		// fmt.Sprint calls Error and String, and prints typed nil pointers
+		// whose methods panic as <nil>
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// logfmtLevelColor returns the ANSI color of a level
+func logfmtLevelColor(level logrus.Level) int {
+	switch level {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return 37 // Gray
+	case logrus.WarnLevel:
+		return 33 // Yellow
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return 31 // Red
+	default:
+		return 36 // Blue
+	}
+}
@@ -0,0 +1,131 @@
+package panlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"zeta":       1,
+		"alpha":      "plain",
+		"request_id": "r-1",
+		"module":     "http",
+		"query":      `name="bob" & x=1`,
+		"empty":      "",
+		"multiline":  "a\nb",
+		"error":      errors.New("not found"),
+		"msg":        "clash",
+	})
+	entry.Time = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry.Level = logrus.WarnLevel
+	entry.Message = "Request done"
+
+	formatter := &LogfmtFormatter{PriorityKeys: []string{"request_id", "module", "missing"}}
+	data, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	expected := `time=2024-05-01T12:00:00Z level=warning msg="Request done" request_id=r-1 module=http ` +
+		`alpha=plain empty="" error="not found" fields.msg=clash multiline="a\nb" ` +
+		`query="name=\"bob\" & x=1" zeta=1` + "\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected logfmt line\n got: %s\nwant: %s", data, expected)
+	}
+
+	// Repeated formatting must give the same order
+	for i := 0; i < 10; i++ {
+		if again, _ := formatter.Format(entry); string(again) != expected {
+			t.Fatalf("Expected deterministic output, got %s", again)
+		}
+	}
+}
+
+// nilStringer is a Stringer and error whose methods panic on a nil receiver
+type nilStringer struct{ name string }
+
+func (s *nilStringer) String() string { return s.name }
+func (s *nilStringer) Error() string  { return s.name }
+
+func TestLogfmtTypedNil(t *testing.T) {
+	var stringer *nilStringer
+	var err error = stringer
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"stringer": fmt.Stringer(stringer),
+		"error":    err,
+	})
+	entry.Message = "Typed nil"
+
+	data, ferr := (&LogfmtFormatter{}).Format(entry)
+	if ferr != nil {
+		t.Fatalf("Failed to format entry: %v", ferr)
+	}
+	if !strings.Contains(string(data), "error=<nil> stringer=<nil>") {
+		t.Errorf("Expected typed nil values to be rendered as <nil>, got %s", data)
+	}
+}
+
+func TestLogfmtColors(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.ErrorLevel
+	entry.Message = "boom"
+
+	colored, _ := (&LogfmtFormatter{Colors: true}).Format(entry)
+	if !strings.Contains(string(colored), "level=\x1b[31merror\x1b[0m") {
+		t.Errorf("Expected colored level, got %q", colored)
+	}
+
+	// Files are never terminals, so the logfmt file output has no colors
+	logFile := filepath.Join("testdata", "logfmt.log")
+	os.Remove(logFile)
+	logger, err := NewLogger(LoggerConfig{LogFile: logFile, Format: "logfmt", LogfmtKeys: []string{"user"}})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.WithFields(logrus.Fields{"a": 1, "user": "alice"}).Error("Failed")
+	logger.Close()
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), "\x1b[") {
+		t.Errorf("Expected no ANSI colors in file, got %q", content)
+	}
+	if !strings.Contains(string(content), "level=error msg=Failed user=alice a=1") {
+		t.Errorf("Expected logfmt line with priority key, got %q", content)
+	}
+}
+
+func TestLoadConfigLogfmtKeys(t *testing.T) {
+	path := filepath.Join("testdata", "logfmt.yaml")
+	content := "format: logfmt\nlogfmt_keys: [request_id, module]\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Format != "logfmt" || strings.Join(config.LogfmtKeys, ",") != "request_id,module" {
+		t.Errorf("Unexpected logfmt config %q %v", config.Format, config.LogfmtKeys)
+	}
+
+	t.Setenv("PANLOGFMT_LOGFMT_KEYS", "trace_id, span_id")
+	config, err = ConfigFromEnv("PANLOGFMT")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if strings.Join(config.LogfmtKeys, ",") != "trace_id,span_id" {
+		t.Errorf("Unexpected logfmt keys %v", config.LogfmtKeys)
+	}
+}
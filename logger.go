@@ -1,9 +1,13 @@
 package panlog
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,131 +17,480 @@ import (
 type LoggerConfig struct {
 	LogLevel      string        // Log level (debug, info, warn, error, fatal, panic)
 	LogFile       string        // Path to log file
-	MaxSize       int64         // Maximum size in bytes before rotation
-	MaxAge        time.Duration // Maximum age of log files to keep
-	MaxBackups    int           // Maximum number of old log files to keep
+	MaxSize       int64         // Maximum size in bytes before rotation (or Unlimited)
+	MaxAge        time.Duration // Maximum age of log files to keep (or Unlimited)
+	MaxBackups    int           // Maximum number of old log files to keep (or Unlimited, NoBackups)
 	Compress      bool          // Whether to compress old log files
 	RotateDaily   bool          // Whether to rotate daily regardless of size
-	JSONFormat    bool          // Whether to use JSON format
+	HashChain     bool          // Whether to record a tamper-evident hash chain of the log file, see Verify
+	Format        string        // Format of every output: text, logfmt, json, ecs, gelf or otlp (defaults to text)
+	JSONFormat    bool          // Deprecated: use Format "json"
 	ConsoleOutput bool          // Whether to output to console as well
+	CountField    string        // Entry field to break level counters down by (e.g. "module")
+	FileLevel     string        // Minimum level written to LogFile (defaults to all entries)
+	FileFormat    string        // Format of LogFile entries (defaults to Format)
+	ConsoleLevel  string        // Minimum level written to the console (defaults to all entries)
+	ConsoleFormat string        // Format of console entries (defaults to Format)
+
+	ServiceName    string   // service.name of ecs entries and otlp resources
+	ServiceVersion string   // service.version of ecs entries and otlp resources
+	LogfmtKeys     []string // Fields the logfmt format writes right after msg
+
+	// Levels of module loggers keyed by module name or wildcard pattern,
+	// e.g. {"db": "warn", "http.*": "debug"}
+	ModuleLevels map[string]string
+
+	// Additional outputs, written to alongside LogFile and the console
+	Sinks []SinkConfig
+
+	// Separate file for high-severity entries, e.g. app.error.log
+	ErrorLog ErrorLogConfig
+
+	// Redaction of sensitive data in messages and fields
+	Redaction RedactionConfig
+
+	// Sampling of repeated messages
+	Sampling SamplingConfig
+
+	// Identical entries within this window are collapsed into one entry
+	// with a repeated count (zero disables deduplication)
+	DedupWindow time.Duration
+
+	// Per-level rate limits keyed by level name, e.g. {"error": {Rate: 100}}
+	RateLimits map[string]RateLimit
+
+	// PEM-encoded ed25519 private key signing the manifest of every backup
+	// of LogFile, see VerifyBackup (optional)
+	ManifestKeyFile string
 }
 
-// Logger wraps logrus with log rotation capabilities
+// Logger wraps logrus with log rotation capabilities. Its logrus Out and
+// Formatter fields are used internally; change the console writer and the
+// formatter with SetOutput and SetFormatter instead.
 type Logger struct {
 	logrus.Logger
-	rotator *LogRotator
-	config  LoggerConfig
+	router    *router
+	counters  *LevelCounter
+	redaction *redactionHook
+	deduper   *deduper
+	sampler   *sampler
+	limiter   *rateLimiter
+
+	mu           sync.Mutex // guards the fields below
+	rotator      *LogRotator
+	errorRotator *LogRotator
+	config       LoggerConfig
+	modules      map[string]*ModuleLogger
+	moduleLevels map[string]logrus.Level
+	overrides    map[string]*levelOverride
+	extractors   []ContextExtractor
+	console      io.Writer // Console writer set by SetOutput
 }
 
 // NewLogger creates a new logger with log rotation
 func NewLogger(config LoggerConfig) (*Logger, error) {
-	// Parse log level
-	level, err := logrus.ParseLevel(config.LogLevel)
-	if err != nil {
-		level = logrus.InfoLevel
-	}
-
-	// Set defaults
-	if config.MaxSize == 0 {
-		config.MaxSize = 100 * 1024 * 1024 // 100MB
-	}
-	if config.MaxAge == 0 {
-		config.MaxAge = 7 * 24 * time.Hour // 7 days
-	}
-	if config.MaxBackups == 0 {
-		config.MaxBackups = 5
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
+	config = withDefaults(config)
 
 	// Create log rotator if file logging is enabled
 	var rotator *LogRotator
-	var writers []io.Writer
-
 	if config.LogFile != "" {
-		rotator, err = NewLogRotator(LogRotatorConfig{
-			FilePath:    config.LogFile,
-			MaxSize:     config.MaxSize,
-			MaxAge:      config.MaxAge,
-			MaxBackups:  config.MaxBackups,
-			Compress:    config.Compress,
-			RotateDaily: config.RotateDaily,
-		})
+		var err error
+		rotator, err = NewLogRotator(config.rotatorConfig())
 		if err != nil {
 			return nil, fmt.Errorf("failed to create log rotator: %w", err)
 		}
-		writers = append(writers, rotator)
 	}
 
-	// Add console output if requested
-	if config.ConsoleOutput {
-		writers = append(writers, os.Stdout)
+	// Create the error log if enabled
+	errorRotator, _, err := openErrorLog(config, nil)
+	if err != nil {
+		if rotator != nil {
+			rotator.Close()
+		}
+		return nil, err
 	}
 
-	// Create multi-writer
-	var output io.Writer
-	if len(writers) == 1 {
-		output = writers[0]
-	} else if len(writers) > 1 {
-		output = io.MultiWriter(writers...)
-	} else {
-		output = os.Stdout
+	// Create the file, error log, console and configured sink outputs
+	outputs, err := openOutputs(config, rotator, errorRotator)
+	if err != nil {
+		if rotator != nil {
+			rotator.Close()
+		}
+		if errorRotator != nil {
+			errorRotator.Close()
+		}
+		return nil, err
 	}
 
-	// Create logger
+	// Create logger. Entries are rendered and written by the router, which
+	// allows Reconfigure to swap formatters and outputs in one step.
+	router := newRouter(outputs)
+	deduper := newDeduper(config.DedupWindow, router.write)
+	sampler := newSampler(config.Sampling, router.write)
+	limiter := newRateLimiter(config.RateLimits, router.write)
+	router.filters = []entryFilter{deduper, sampler, limiter}
 	logger := &Logger{
 		Logger: logrus.Logger{
-			Out:       output,
-			Formatter: getFormatter(config.JSONFormat),
-			Level:     level,
+			Out:       io.Discard,
+			Formatter: router,
+			Hooks:     make(logrus.LevelHooks),
+			Level:     parseLevel(config.LogLevel),
 		},
-		rotator: rotator,
-		config:  config,
+		router:       router,
+		counters:     NewLevelCounter(config.CountField),
+		redaction:    &redactionHook{redactor: newRedactor(config.Redaction)},
+		deduper:      deduper,
+		sampler:      sampler,
+		limiter:      limiter,
+		rotator:      rotator,
+		errorRotator: errorRotator,
+		config:       config,
+		modules:      make(map[string]*ModuleLogger),
+		moduleLevels: parseModuleLevels(config.ModuleLevels),
+		overrides:    make(map[string]*levelOverride),
+		extractors:   append([]ContextExtractor(nil), defaultExtractors...),
 	}
+	// Redaction runs first so that no other hook sees sensitive data
+	logger.AddHook(logger.redaction)
+	logger.AddHook(logger.counters)
 
 	return logger, nil
 }
 
-// Close closes the logger and its underlying rotator
-func (l *Logger) Close() error {
-	if l.rotator != nil {
-		return l.rotator.Close()
+// Reconfigure applies a new configuration to a running logger. The level,
+// formatters, console output, sinks and rotation limits are replaced without
+// losing the open log file; if LogFile changes, the new file is opened before
+// the old one is closed so that no entry is lost during the handover. Sinks
+// that are not part of the new configuration are closed.
+func (l *Logger) Reconfigure(config LoggerConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	config = withDefaults(config)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Open and check everything that may fail before changing anything, so
+	// that a failed Reconfigure leaves the logger as it was
+	rotator := l.rotator
+	applyRotator := func() {}
+	var err error
+	switch {
+	case config.LogFile == "":
+		rotator = nil
+	case rotator == nil || config.LogFile != l.config.LogFile:
+		rotator, err = NewLogRotator(config.rotatorConfig())
+		if err != nil {
+			return fmt.Errorf("failed to create log rotator: %w", err)
+		}
+	default:
+		applyRotator, err = rotator.prepareReconfigure(config.rotatorConfig())
+		if err != nil {
+			return fmt.Errorf("failed to reconfigure log rotator: %w", err)
+		}
+	}
+
+	errorRotator, applyErrorLog, err := openErrorLog(config, l.errorRotator)
+	if err != nil {
+		if rotator != nil && rotator != l.rotator {
+			rotator.Close()
+		}
+		return err
+	}
+
+	outputs, err := openOutputs(config, rotator, errorRotator)
+	if err != nil {
+		if rotator != nil && rotator != l.rotator {
+			rotator.Close()
+		}
+		if errorRotator != nil && errorRotator != l.errorRotator {
+			errorRotator.Close()
+		}
+		return err
+	}
+	var replaced *output
+	if l.console != nil {
+		outputs, replaced = l.withConsole(config, outputs)
+	}
+
+	applyRotator()
+	applyErrorLog()
+	previous := l.router.swap(outputs)
+	l.redaction.swap(newRedactor(config.Redaction))
+	l.deduper.swap(config.DedupWindow)
+	l.sampler.swap(config.Sampling)
+	l.limiter.swap(config.RateLimits)
+	l.cancelOverrides()
+	l.moduleLevels = parseModuleLevels(config.ModuleLevels)
+	l.setLevel(parseLevel(config.LogLevel))
+	l.counters.setField(config.CountField)
+
+	l.rotator = rotator
+	l.errorRotator = errorRotator
+	l.config = config
+
+	// The router no longer writes to the previous sinks once swap returns
+	err = closeOutputs(previous, outputs)
+	if replaced != nil {
+		err = errors.Join(err, replaced.sink.Close())
+	}
+	return err
+}
+
+// SetOutput makes the console output write to out, enabling it if
+// ConsoleOutput is off. The file, error log and sink outputs are not
+// affected, and the writer is kept across Reconfigure. It replaces
+// logrus.Logger.SetOutput, whose writer the logger does not use.
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.console = out
+	outputs, replaced := l.withConsole(l.config, l.router.current())
+	l.router.swap(outputs)
+	if replaced != nil {
+		closeOutputs([]*output{replaced}, nil)
 	}
-	return nil
 }
 
-// Rotate manually triggers log rotation
+// withConsole returns outputs with the console output writing to the
+// SetOutput writer, and the console output it replaced if there was one;
+// the caller must hold l.mu
+func (l *Logger) withConsole(config LoggerConfig, outputs []*output) ([]*output, *output) {
+	console := newOutput("console", config.ConsoleLevel, config.formatter(config.ConsoleFormat, false), NewWriterSink(l.console))
+	console.console = true
+
+	var replaced *output
+	result := make([]*output, 0, len(outputs)+1)
+	for _, out := range outputs {
+		if out.console {
+			replaced = out
+			out = console
+		}
+		result = append(result, out)
+	}
+	if replaced == nil {
+		result = append(result, console)
+	}
+	return result, replaced
+}
+
+// SetFormatter makes every output render entries with formatter instead of
+// its configured format, until it is called with nil. It replaces
+// logrus.Logger.SetFormatter: the logrus formatter of the logger writes the
+// entries to the outputs and must not be changed.
+func (l *Logger) SetFormatter(formatter logrus.Formatter) {
+	l.router.setFormatter(formatter)
+}
+
+// Close closes the logger, all of its sinks and the error log
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Write the pending duplicates and summaries before the outputs are
+	// closed
+	l.deduper.close()
+	l.sampler.close()
+	l.limiter.close()
+
+	return closeOutputs(l.router.swap(nil), nil)
+}
+
+// Rotate manually triggers log rotation of every file sink and the error log
 func (l *Logger) Rotate() error {
-	if l.rotator != nil {
-		return l.rotator.Rotate()
+	var errs []error
+	for _, out := range l.router.current() {
+		if r, ok := out.sink.(interface{ Rotate() error }); ok {
+			if err := r.Rotate(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to rotate %s: %w", out.name, err))
+			}
+		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // GetStats returns statistics about the logger and rotator
 func (l *Logger) GetStats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	stats := map[string]interface{}{
-		"config": l.config,
+		"config": l.config.withoutSecrets(),
 	}
 
 	if l.rotator != nil {
 		stats["rotator"] = l.rotator.GetStats()
 	}
 
+	stats["sinks"] = sinkStats(l.router.current())
+	if l.errorRotator != nil {
+		stats["error_log"] = l.errorRotator.GetStats()
+	}
+	stats["counters"] = l.counters.Snapshot()
+	stats["sampling"] = l.sampler.stats()
+	stats["dedup"] = l.deduper.stats()
+	stats["rate_limits"] = l.limiter.stats()
+
 	return stats
 }
 
-// getFormatter returns the appropriate formatter based on configuration
-func getFormatter(jsonFormat bool) logrus.Formatter {
-	if jsonFormat {
+// Counters returns the per-level entry counts since creation or the last reset
+func (l *Logger) Counters() CounterSnapshot {
+	return l.counters.Snapshot()
+}
+
+// ResetCounters returns the current entry counts and resets them to zero
+func (l *Logger) ResetCounters() CounterSnapshot {
+	return l.counters.Reset()
+}
+
+// withDefaults fills in the default retention limits
+func withDefaults(config LoggerConfig) LoggerConfig {
+	if config.MaxSize == 0 {
+		config.MaxSize = 100 * 1024 * 1024 // 100MB
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = 7 * 24 * time.Hour // 7 days
+	}
+	if config.MaxBackups == 0 {
+		config.MaxBackups = 5
+	}
+	return config
+}
+
+// rotatorConfig returns the LogRotatorConfig for the configured log file
+func (c LoggerConfig) rotatorConfig() LogRotatorConfig {
+	return LogRotatorConfig{
+		FilePath:    c.LogFile,
+		MaxSize:     c.MaxSize,
+		MaxAge:      c.MaxAge,
+		MaxBackups:  c.MaxBackups,
+		Compress:    c.Compress,
+		RotateDaily: c.RotateDaily,
+		HashChain:   c.HashChain,
+
+		ManifestKeyFile: c.ManifestKeyFile,
+	}
+}
+
+// withoutSecrets returns a copy of the configuration with the redaction
+// salt, sink credentials, URL passwords and TLS settings masked, for GetStats
+func (c LoggerConfig) withoutSecrets() LoggerConfig {
+	if c.Redaction.Salt != "" {
+		c.Redaction.Salt = defaultMask
+	}
+
+	sinks := make([]SinkConfig, len(c.Sinks))
+	for i, sc := range c.Sinks {
+		sc.HTTP = sc.HTTP.withoutSecrets()
+		sc.OTLP.HTTPConfig = sc.OTLP.HTTPConfig.withoutSecrets()
+		if sc.Network.TLS != nil {
+			// Certificates include their private keys
+			sc.Network.TLS = &tls.Config{
+				ServerName:         sc.Network.TLS.ServerName,
+				InsecureSkipVerify: sc.Network.TLS.InsecureSkipVerify,
+			}
+		}
+		if sc.Options != nil {
+			options := make(map[string]string, len(sc.Options))
+			for key, value := range sc.Options {
+				switch {
+				case key == "auth_token" || strings.HasPrefix(key, "header."):
+					value = defaultMask
+				case key == "url":
+					value = redactedURL(value)
+				}
+				options[key] = value
+			}
+			sc.Options = options
+		}
+		sinks[i] = sc
+	}
+	if c.Sinks != nil {
+		c.Sinks = sinks
+	}
+	return c
+}
+
+// parseLevel parses a validated level name, defaulting to info
+func parseLevel(name string) logrus.Level {
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return logrus.InfoLevel
+	}
+	return level
+}
+
+// formatName returns the format of an output, falling back to Format
+func (c LoggerConfig) formatName(format string) string {
+	switch {
+	case format != "":
+		return format
+	case c.Format != "":
+		return c.Format
+	case c.JSONFormat:
+		return "json"
+	}
+	return "text"
+}
+
+// outputLevel parses the validated minimum level of an output. Outputs
+// without a level accept every entry the logger lets through.
+func outputLevel(name string) logrus.Level {
+	if name == "" {
+		return logrus.TraceLevel
+	}
+	return parseLevel(name)
+}
+
+// formats lists the names accepted by formatter
+var formats = []string{"text", "logfmt", "json", "ecs", "gelf", "otlp"}
+
+// formatter returns the formatter of an output with the given format, which
+// defaults to Format. Colors are only used for text written to a terminal.
+func (c LoggerConfig) formatter(format string, colors bool) logrus.Formatter {
+	switch c.formatName(format) {
+	case "json":
 		return &logrus.JSONFormatter{
 			TimestampFormat: time.RFC3339,
 		}
+	case "ecs":
+		return &ECSFormatter{
+			ServiceName:    c.ServiceName,
+			ServiceVersion: c.ServiceVersion,
+		}
+	case "gelf":
+		return &GELFFormatter{Host: gelfHostname()}
+	case "otlp":
+		return &OTLPFormatter{}
+	case "logfmt":
+		return &LogfmtFormatter{
+			TimestampFormat: time.RFC3339,
+			PriorityKeys:    c.LogfmtKeys,
+			Colors:          colors,
+		}
 	}
 
 	return &logrus.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: time.RFC3339,
-		DisableColors:   false,
+		ForceColors:     colors,
+		DisableColors:   !colors,
+	}
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
 	}
+	return stat.Mode()&os.ModeCharDevice != 0
 }
 
 // DefaultLogger creates a logger with sensible defaults
@@ -150,7 +503,7 @@ func DefaultLogger() (*Logger, error) {
 		MaxBackups:    5,
 		Compress:      true,
 		RotateDaily:   true,
-		JSONFormat:    false,
+		Format:        "text",
 		ConsoleOutput: true,
 	})
 }
@@ -165,7 +518,7 @@ func DevelopmentLogger() (*Logger, error) {
 		MaxBackups:    3,
 		Compress:      false,
 		RotateDaily:   true,
-		JSONFormat:    false,
+		Format:        "text",
 		ConsoleOutput: true,
 	})
 }
@@ -180,7 +533,8 @@ func ProductionLogger() (*Logger, error) {
 		MaxBackups:    10,
 		Compress:      true,
 		RotateDaily:   true,
-		JSONFormat:    true,
+		Format:        "json",
 		ConsoleOutput: false,
+		Redaction:     RedactionConfig{Rules: DefaultRedactionRules()},
 	})
 }
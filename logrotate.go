@@ -2,6 +2,10 @@ package panlog
 
 import (
 	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -24,37 +28,47 @@ type LogRotator struct {
 	compress    bool
 	rotateDaily bool
 	rotateTime  time.Time
+	hashChain   bool
+	manifestKey ed25519.PrivateKey
 
 	// Current file handle
 	file     *os.File
 	fileSize int64
+
+	// Hash chain of the current file and the head it continues from
+	chain     *hashChain
+	chainHead [sha256.Size]byte
 }
 
 // LogRotatorConfig holds configuration for log rotation
 type LogRotatorConfig struct {
 	FilePath    string        // Path to the log file
-	MaxSize     int64         // Maximum size in bytes before rotation
-	MaxAge      time.Duration // Maximum age of log files to keep
-	MaxBackups  int           // Maximum number of old log files to keep
+	MaxSize     int64         // Maximum size in bytes before rotation (or Unlimited)
+	MaxAge      time.Duration // Maximum age of log files to keep (or Unlimited)
+	MaxBackups  int           // Maximum number of old log files to keep (or Unlimited, NoBackups)
 	Compress    bool          // Whether to compress old log files
 	RotateDaily bool          // Whether to rotate daily regardless of size
+	HashChain   bool          // Whether to record a tamper-evident hash chain of the lines, see Verify
+
+	// PEM-encoded ed25519 private key signing the manifest of every backup,
+	// see VerifyBackup (optional)
+	ManifestKeyFile string
 }
 
 // NewLogRotator creates a new log rotator with the given configuration
 func NewLogRotator(config LogRotatorConfig) (*LogRotator, error) {
-	if config.FilePath == "" {
-		return nil, fmt.Errorf("file path is required")
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Set defaults
-	if config.MaxSize == 0 {
-		config.MaxSize = 100 * 1024 * 1024 // 100MB default
-	}
-	if config.MaxAge == 0 {
-		config.MaxAge = 7 * 24 * time.Hour // 7 days default
-	}
-	if config.MaxBackups == 0 {
-		config.MaxBackups = 5 // 5 backups default
+	config = rotatorWithDefaults(config)
+
+	var manifestKey ed25519.PrivateKey
+	if config.ManifestKeyFile != "" {
+		var err error
+		if manifestKey, err = loadManifestKey(config.ManifestKeyFile); err != nil {
+			return nil, err
+		}
 	}
 
 	lr := &LogRotator{
@@ -65,6 +79,8 @@ func NewLogRotator(config LogRotatorConfig) (*LogRotator, error) {
 		compress:    config.Compress,
 		rotateDaily: config.RotateDaily,
 		rotateTime:  time.Now().Truncate(24 * time.Hour),
+		hashChain:   config.HashChain,
+		manifestKey: manifestKey,
 	}
 
 	// Create directory if it doesn't exist
@@ -73,6 +89,15 @@ func NewLogRotator(config LogRotatorConfig) (*LogRotator, error) {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
+	// Continue the hash chain of the newest backup
+	if lr.hashChain {
+		head, err := lr.backupChainHead()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hash chain: %w", err)
+		}
+		lr.chainHead = head
+	}
+
 	// Open the current log file
 	if err := lr.openFile(); err != nil {
 		return nil, err
@@ -81,24 +106,88 @@ func NewLogRotator(config LogRotatorConfig) (*LogRotator, error) {
 	return lr, nil
 }
 
+// rotatorWithDefaults fills in the default rotation and retention limits
+func rotatorWithDefaults(config LogRotatorConfig) LogRotatorConfig {
+	if config.MaxSize == 0 {
+		config.MaxSize = 100 * 1024 * 1024 // 100MB default
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = 7 * 24 * time.Hour // 7 days default
+	}
+	if config.MaxBackups == 0 {
+		config.MaxBackups = 5 // 5 backups default
+	}
+	return config
+}
+
 // Write implements io.Writer interface
 func (lr *LogRotator) Write(p []byte) (n int, err error) {
 	lr.mu.Lock()
 	defer lr.mu.Unlock()
 
-	// Check if we need to rotate
-	if err := lr.checkRotation(); err != nil {
-		return 0, err
+	// Check if we need to rotate. A failed rotation reopens the live file,
+	// so the entry is still written if that succeeded.
+	rotateErr := lr.checkRotation()
+	if lr.file == nil {
+		return 0, rotateErr
 	}
 
 	// Write to file
 	n, err = lr.file.Write(p)
+	lr.fileSize += int64(n)
+	if lr.chain != nil {
+		if cerr := lr.chain.write(p[:n]); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return n, errors.Join(rotateErr, err)
+}
+
+// Reconfigure updates the rotation and retention limits. The file path
+// cannot be changed; create a new LogRotator instead.
+func (lr *LogRotator) Reconfigure(config LogRotatorConfig) error {
+	apply, err := lr.prepareReconfigure(config)
 	if err != nil {
-		return n, err
+		return err
 	}
+	apply()
+	return nil
+}
 
-	lr.fileSize += int64(n)
-	return n, nil
+// prepareReconfigure checks a new configuration and returns a function
+// applying it. The rotator is left unchanged until the function is called,
+// so that callers can apply it together with other changes that may fail.
+func (lr *LogRotator) prepareReconfigure(config LogRotatorConfig) (func(), error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.FilePath != lr.filePath {
+		return nil, fmt.Errorf("cannot change file path from %q to %q", lr.filePath, config.FilePath)
+	}
+	if config.HashChain != lr.hashChain {
+		return nil, fmt.Errorf("cannot enable or disable the hash chain of %q", lr.filePath)
+	}
+	config = rotatorWithDefaults(config)
+
+	var manifestKey ed25519.PrivateKey
+	if config.ManifestKeyFile != "" {
+		var err error
+		if manifestKey, err = loadManifestKey(config.ManifestKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		lr.mu.Lock()
+		defer lr.mu.Unlock()
+
+		lr.manifestKey = manifestKey
+		lr.maxSize = config.MaxSize
+		lr.maxAge = config.MaxAge
+		lr.maxBackups = config.MaxBackups
+		lr.compress = config.Compress
+		lr.rotateDaily = config.RotateDaily
+	}, nil
 }
 
 // Close closes the log rotator and the underlying file
@@ -106,10 +195,17 @@ func (lr *LogRotator) Close() error {
 	lr.mu.Lock()
 	defer lr.mu.Unlock()
 
+	var err error
+	if lr.chain != nil {
+		err = lr.chain.close()
+		lr.chain = nil
+	}
 	if lr.file != nil {
-		return lr.file.Close()
+		if ferr := lr.file.Close(); ferr != nil {
+			err = ferr
+		}
 	}
-	return nil
+	return err
 }
 
 // Rotate manually triggers a log rotation
@@ -120,6 +216,14 @@ func (lr *LogRotator) Rotate() error {
 	return lr.rotate()
 }
 
+// size returns the size of the current log file
+func (lr *LogRotator) size() int64 {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	return lr.fileSize
+}
+
 // checkRotation checks if rotation is needed and performs it
 func (lr *LogRotator) checkRotation() error {
 	now := time.Now()
@@ -137,49 +241,101 @@ func (lr *LogRotator) checkRotation() error {
 	}
 
 	// Check size-based rotation
-	if lr.fileSize >= lr.maxSize {
+	if lr.maxSize > 0 && lr.fileSize >= lr.maxSize {
 		return lr.rotate()
 	}
 
 	return nil
 }
 
-// rotate performs the actual log rotation
+// rotate performs the actual log rotation. Until the log file is renamed a
+// failure aborts the rotation; afterwards the remaining steps are still
+// carried out. Either way the live file is reopened so that writing
+// continues, and the errors are returned.
 func (lr *LogRotator) rotate() error {
 	if lr.file == nil {
 		return lr.openFile()
 	}
 
 	// Close current file
-	if err := lr.file.Close(); err != nil {
-		return fmt.Errorf("failed to close log file: %w", err)
+	err := lr.file.Close()
+	lr.file = nil
+	if err != nil {
+		return lr.reopen(fmt.Errorf("failed to close log file: %w", err))
 	}
 
 	// Generate rotated filename
 	rotatedName := lr.generateRotatedName()
 	if rotatedName == "" {
-		return fmt.Errorf("failed to generate rotated filename")
+		return lr.reopen(fmt.Errorf("failed to generate rotated filename"))
 	}
 
 	// Rename current file to rotated name
 	if err := os.Rename(lr.filePath, rotatedName); err != nil {
-		return fmt.Errorf("failed to rename log file: %w", err)
+		return lr.reopen(fmt.Errorf("failed to rename log file: %w", err))
+	}
+
+	var errs []error
+
+	// Seal the hash chain; the next file continues from its head
+	if lr.chain != nil {
+		if err := lr.chain.seal(); err != nil {
+			errs = append(errs, err)
+		}
+		lr.chainHead = lr.chain.head
+		lr.chain = nil
+		if err := os.Rename(chainPath(lr.filePath), chainPath(rotatedName)); err != nil {
+			// A sealed chain left at the live path keeps it from reopening
+			os.Remove(chainPath(lr.filePath))
+			errs = append(errs, fmt.Errorf("failed to rename hash chain: %w", err))
+		}
 	}
 
-	// Compress if enabled
-	if lr.compress {
-		if err := lr.compressFile(rotatedName); err != nil {
-			return fmt.Errorf("failed to compress log file: %w", err)
+	// Discard the rotated file if no backups are kept, otherwise compress if enabled
+	if lr.maxBackups == NoBackups {
+		if err := os.Remove(rotatedName); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove rotated log file: %w", err))
+		}
+		os.Remove(chainPath(rotatedName))
+	} else {
+		backup := rotatedName
+		if lr.compress {
+			if err := lr.compressFile(rotatedName); err != nil {
+				errs = append(errs, fmt.Errorf("failed to compress log file: %w", err))
+			} else {
+				backup += ".gz"
+			}
+		}
+
+		// Sign the backup once it is completely written
+		if lr.manifestKey != nil {
+			var chainHead string
+			if lr.hashChain {
+				chainHead = hex.EncodeToString(lr.chainHead[:])
+			}
+			if err := writeManifest(backup, lr.manifestKey, chainHead); err != nil {
+				errs = append(errs, fmt.Errorf("failed to write manifest: %w", err))
+			}
 		}
 	}
 
 	// Clean up old files
 	if err := lr.cleanup(); err != nil {
-		return fmt.Errorf("failed to cleanup old files: %w", err)
+		errs = append(errs, fmt.Errorf("failed to cleanup old files: %w", err))
 	}
 
 	// Open new file
-	return lr.openFile()
+	return lr.reopen(errors.Join(errs...))
+}
+
+// reopen opens the live file after a rotation, returning err together with
+// any error from opening it. An unsealed hash chain is resumed.
+func (lr *LogRotator) reopen(err error) error {
+	if lr.chain != nil {
+		lr.chain.close()
+		lr.chain = nil
+	}
+	return errors.Join(err, lr.openFile())
 }
 
 // generateRotatedName generates the name for the rotated log file
@@ -188,9 +344,20 @@ func (lr *LogRotator) generateRotatedName() string {
 	ext := filepath.Ext(lr.filePath)
 	base := strings.TrimSuffix(lr.filePath, ext)
 
-	// Format: filename-YYYY-MM-DD-HHMMSS.ext
+	// Format: filename-YYYY-MM-DD-HHMMSS.ext, with a counter appended when
+	// the file was already rotated within the same second
 	timestamp := now.Format("2006-01-02-150405")
-	return fmt.Sprintf("%s-%s%s", base, timestamp, ext)
+	name := fmt.Sprintf("%s-%s%s", base, timestamp, ext)
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s-%d%s", base, timestamp, i, ext)
+	}
+	return name
+}
+
+// fileExists reports whether a file exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 // openFile opens the current log file
@@ -207,13 +374,24 @@ func (lr *LogRotator) openFile() error {
 		return fmt.Errorf("failed to get file stats: %w", err)
 	}
 
+	if lr.hashChain {
+		chain, err := openHashChain(lr.filePath, lr.chainHead)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		lr.chain = chain
+	}
+
 	lr.file = file
 	lr.fileSize = stat.Size()
 	return nil
 }
 
-// compressFile compresses a log file using gzip
-func (lr *LogRotator) compressFile(filename string) error {
+// compressFile compresses a log file using gzip. The original file is only
+// removed once the compressed file is completely written; on failure the
+// partial compressed file is removed and the original kept.
+func (lr *LogRotator) compressFile(filename string) (err error) {
 	// Skip if already compressed
 	if strings.HasSuffix(filename, ".gz") {
 		return nil
@@ -231,23 +409,41 @@ func (lr *LogRotator) compressFile(filename string) error {
 	if err != nil {
 		return err
 	}
-	defer compressed.Close()
+	defer func() {
+		if err != nil {
+			compressed.Close()
+			os.Remove(filename + ".gz")
+		}
+	}()
 
-	// Create gzip writer
+	// Copy content; closing the gzip writer writes the trailer
 	gw := gzip.NewWriter(compressed)
-	defer gw.Close()
-
-	// Copy content
 	if _, err := io.Copy(gw, source); err != nil {
 		return err
 	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	if err := compressed.Sync(); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
 
 	// Remove original file
+	source.Close()
 	return os.Remove(filename)
 }
 
-// cleanup removes old log files based on age and count
-func (lr *LogRotator) cleanup() error {
+// rotatedFile is a backup left by a rotation
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// backups returns the rotated files of the log, oldest first
+func (lr *LogRotator) backups() ([]rotatedFile, error) {
 	dir := filepath.Dir(lr.filePath)
 	base := filepath.Base(lr.filePath)
 	ext := filepath.Ext(base)
@@ -257,49 +453,74 @@ func (lr *LogRotator) cleanup() error {
 	pattern := filepath.Join(dir, baseWithoutExt+"-*"+ext+"*")
 	matches, err := filepath.Glob(pattern)
 	if err != nil {
-		return err
-	}
-
-	// Sort files by modification time (oldest first)
-	var files []struct {
-		path    string
-		modTime time.Time
+		return nil, err
 	}
 
+	var files []rotatedFile
 	for _, match := range matches {
+		if strings.HasSuffix(match, chainSuffix) || strings.HasSuffix(match, manifestSuffix) {
+			continue
+		}
 		stat, err := os.Stat(match)
 		if err != nil {
 			continue
 		}
-		files = append(files, struct {
-			path    string
-			modTime time.Time
-		}{match, stat.ModTime()})
+		files = append(files, rotatedFile{match, stat.ModTime()})
 	}
 
+	// Sort by modification time; files rotated within the same second sort
+	// by their counter suffix
 	sort.Slice(files, func(i, j int) bool {
-		return files[i].modTime.Before(files[j].modTime)
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		if len(files[i].path) != len(files[j].path) {
+			return len(files[i].path) < len(files[j].path)
+		}
+		return files[i].path < files[j].path
 	})
+	return files, nil
+}
+
+// cleanup removes old log files based on age and count
+func (lr *LogRotator) cleanup() error {
+	files, err := lr.backups()
+	if err != nil {
+		return err
+	}
 
 	// Remove files based on age
-	cutoff := time.Now().Add(-lr.maxAge)
-	for _, file := range files {
-		if file.modTime.Before(cutoff) {
-			os.Remove(file.path)
+	if lr.maxAge > 0 {
+		cutoff := time.Now().Add(-lr.maxAge)
+		for _, file := range files {
+			if file.modTime.Before(cutoff) {
+				removeBackup(file.path)
+			}
 		}
 	}
 
 	// Remove files based on count
-	if len(files) > lr.maxBackups {
-		toRemove := len(files) - lr.maxBackups
+	keep := lr.maxBackups
+	if keep == NoBackups {
+		keep = 0
+	}
+	if keep >= 0 && len(files) > keep {
+		toRemove := len(files) - keep
 		for i := 0; i < toRemove && i < len(files); i++ {
-			os.Remove(files[i].path)
+			removeBackup(files[i].path)
 		}
 	}
 
 	return nil
 }
 
+// removeBackup removes a backup, its hash chain and its manifest
+func removeBackup(path string) {
+	os.Remove(path)
+	os.Remove(chainPath(path))
+	os.Remove(manifestPath(path))
+}
+
 // GetStats returns current statistics about the log rotator
 func (lr *LogRotator) GetStats() map[string]interface{} {
 	lr.mu.Lock()
@@ -322,5 +543,14 @@ func (lr *LogRotator) GetStats() map[string]interface{} {
 		stats["file_open"] = false
 	}
 
+	if lr.hashChain {
+		head := lr.chainHead
+		if lr.chain != nil {
+			head = lr.chain.head
+		}
+		stats["chain_head"] = hex.EncodeToString(head[:])
+	}
+	stats["signed_manifests"] = lr.manifestKey != nil
+
 	return stats
 }
@@ -82,7 +82,7 @@ func ExampleUsage() {
 		MaxBackups:    3,
 		Compress:      false,
 		RotateDaily:   true,
-		JSONFormat:    false,
+		Format:        "text",
 		ConsoleOutput: false, // Only file output
 	})
 	if err != nil {
@@ -169,7 +169,7 @@ func ExampleStructuredLogging() {
 		LogLevel:      "info",
 		LogFile:       "logs/structured.log",
 		RotateDaily:   true,
-		JSONFormat:    true,
+		Format:        "json",
 		ConsoleOutput: true,
 	})
 	if err != nil {
@@ -0,0 +1,308 @@
+package panlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// manifestSuffix is the extension of backup manifests
+const manifestSuffix = ".manifest"
+
+// Manifest describes a backup left by a rotation. When a LogRotator has a
+// manifest key, it writes the signed manifest of every backup next to it,
+// e.g. app-2024-01-02-150405.log.manifest for app-2024-01-02-150405.log.gz.
+type Manifest struct {
+	File           string     `json:"file"`                      // Base name of the backup
+	Size           int64      `json:"size"`                      // Size of the backup as stored
+	SHA256         string     `json:"sha256"`                    // Hash of the backup as stored, i.e. compressed
+	Lines          int        `json:"lines"`                     // Number of lines
+	FirstTimestamp *time.Time `json:"first_timestamp,omitempty"` // Time of the first entry, if it could be parsed
+	LastTimestamp  *time.Time `json:"last_timestamp,omitempty"`  // Time of the last entry, if it could be parsed
+	ChainHead      string     `json:"chain_head,omitempty"`      // Head of the hash chain, if enabled
+	Rotated        time.Time  `json:"rotated"`                   // Time of the rotation
+}
+
+// signedManifest is the on-disk form of a manifest. The signature covers
+// the manifest exactly as stored.
+type signedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"` // Base64-encoded ed25519 signature
+}
+
+// ManifestError reports a backup that does not match its manifest
+type ManifestError struct {
+	File   string // Backup
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("manifest check failed for %s: %s", e.File, e.Reason)
+}
+
+// manifestPath returns the manifest path of a backup
+func manifestPath(path string) string {
+	return strings.TrimSuffix(path, ".gz") + manifestSuffix
+}
+
+// loadManifestKey reads a PEM-encoded PKCS #8 ed25519 private key, as
+// written by "openssl genpkey -algorithm ed25519"
+func loadManifestKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("manifest key %s is not a PEM-encoded private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("manifest key %s is not an ed25519 key", path)
+	}
+	return privateKey, nil
+}
+
+// LoadManifestPublicKey reads the key verifying manifests from a PEM file
+// holding either the public key or the private key itself
+func LoadManifestPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("manifest key %s is not PEM-encoded", path)
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest key: %w", err)
+		}
+		if publicKey, ok := key.(ed25519.PublicKey); ok {
+			return publicKey, nil
+		}
+	case "PRIVATE KEY":
+		privateKey, err := loadManifestKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.Public().(ed25519.PublicKey), nil
+	}
+	return nil, fmt.Errorf("manifest key %s is not an ed25519 key", path)
+}
+
+// newManifest describes the backup at path
+func newManifest(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Hash the stored bytes while reading the lines
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(file, hash)}
+	var r io.Reader = counter
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(counter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compressed backup: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	manifest := &Manifest{File: filepath.Base(path)}
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			manifest.Lines++
+			if t, ok := entryTimestamp(line); ok {
+				if manifest.FirstTimestamp == nil {
+					manifest.FirstTimestamp = &t
+				}
+				manifest.LastTimestamp = &t
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup: %w", err)
+		}
+	}
+
+	// Include trailing bytes the decompressor did not need
+	if _, err := io.Copy(io.Discard, counter); err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	manifest.Size = counter.n
+	manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return manifest, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// entryTimestamp extracts the time of an entry rendered by one of the
+// formatters, or reports false if it has none
+func entryTimestamp(line []byte) (time.Time, bool) {
+	line = bytes.TrimSpace(line)
+
+	if bytes.HasPrefix(line, []byte("{")) {
+		var fields struct {
+			Time         string          `json:"time"`         // json
+			ECSTimestamp string          `json:"@timestamp"`   // ecs
+			Timestamp    json.RawMessage `json:"timestamp"`    // gelf
+			TimeUnixNano string          `json:"timeUnixNano"` // otlp
+		}
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return time.Time{}, false
+		}
+		for _, value := range []string{fields.Time, fields.ECSTimestamp} {
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t, true
+			}
+		}
+		if seconds, err := strconv.ParseFloat(string(fields.Timestamp), 64); err == nil {
+			return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), true
+		}
+		if nanos, err := strconv.ParseInt(fields.TimeUnixNano, 10, 64); err == nil {
+			return time.Unix(0, nanos).UTC(), true
+		}
+		return time.Time{}, false
+	}
+
+	// text and logfmt
+	for rest := line; ; {
+		i := bytes.Index(rest, []byte("time="))
+		if i < 0 {
+			return time.Time{}, false
+		}
+		if i > 0 && rest[i-1] != ' ' {
+			rest = rest[i+len("time="):]
+			continue
+		}
+
+		value := string(rest[i+len("time="):])
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return time.Time{}, false
+			}
+			value, _ = strconv.Unquote(quoted)
+		} else if end := strings.IndexByte(value, ' '); end >= 0 {
+			value = value[:end]
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		return t, err == nil
+	}
+}
+
+// writeManifest writes the signed manifest of a backup
+func writeManifest(path string, key ed25519.PrivateKey, chainHead string) error {
+	manifest, err := newManifest(path)
+	if err != nil {
+		return err
+	}
+	manifest.ChainHead = chainHead
+	manifest.Rotated = time.Now().UTC()
+
+	payload, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	signed, err := json.Marshal(signedManifest{
+		Manifest:  payload,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	return os.WriteFile(manifestPath(path), append(signed, '\n'), 0644)
+}
+
+// ReadManifest reads the manifest of the backup at path and checks its
+// signature, without checking the backup itself
+func ReadManifest(path string, publicKey ed25519.PublicKey) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var signed signedManifest
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, &ManifestError{File: path, Reason: fmt.Sprintf("invalid manifest: %v", err)}
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || !ed25519.Verify(publicKey, signed.Manifest, signature) {
+		return nil, &ManifestError{File: path, Reason: "invalid signature"}
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(signed.Manifest, &manifest); err != nil {
+		return nil, &ManifestError{File: path, Reason: fmt.Sprintf("invalid manifest: %v", err)}
+	}
+	return &manifest, nil
+}
+
+// VerifyBackup checks the signature of the manifest of the backup at path
+// and that the backup matches it. It returns the manifest, or a
+// *ManifestError if the backup was modified, renamed or re-signed with
+// another key.
+func VerifyBackup(path string, publicKey ed25519.PublicKey) (*Manifest, error) {
+	manifest, err := ReadManifest(path, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := newManifest(path)
+	if err != nil {
+		return nil, &ManifestError{File: path, Reason: fmt.Sprintf("unreadable backup: %v", err)}
+	}
+	switch {
+	case actual.File != manifest.File:
+		return nil, &ManifestError{File: path, Reason: fmt.Sprintf("manifest is for %s", manifest.File)}
+	case actual.Size != manifest.Size:
+		return nil, &ManifestError{File: path, Reason: fmt.Sprintf("size is %d, want %d", actual.Size, manifest.Size)}
+	case actual.SHA256 != manifest.SHA256:
+		return nil, &ManifestError{File: path, Reason: "SHA-256 does not match"}
+	case actual.Lines != manifest.Lines:
+		return nil, &ManifestError{File: path, Reason: fmt.Sprintf("%d lines, want %d", actual.Lines, manifest.Lines)}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,214 @@
+package panlog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeManifestKeys writes a new ed25519 key pair as PEM files and returns
+// their paths
+func writeManifestKeys(t *testing.T, dir string) (string, string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal private key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	privatePath := filepath.Join(dir, "manifest.key")
+	publicPath := filepath.Join(dir, "manifest.pub")
+	os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600)
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0644)
+	return privatePath, publicPath
+}
+
+// newManifestRotator creates a rotator signing manifests in a fresh
+// directory and returns it with the verification key
+func newManifestRotator(t *testing.T, name string, config LogRotatorConfig) (*LogRotator, ed25519.PublicKey) {
+	dir := filepath.Join("testdata", "manifest", name)
+	os.RemoveAll(dir)
+
+	privatePath, publicPath := writeManifestKeys(t, filepath.Join(dir, "keys"))
+	config.FilePath = filepath.Join(dir, "app.log")
+	config.ManifestKeyFile = privatePath
+	lr, err := NewLogRotator(config)
+	if err != nil {
+		t.Fatalf("Failed to create log rotator: %v", err)
+	}
+	t.Cleanup(func() { lr.Close() })
+
+	publicKey, err := LoadManifestPublicKey(publicPath)
+	if err != nil {
+		t.Fatalf("Failed to load public key: %v", err)
+	}
+	return lr, publicKey
+}
+
+// rotateBackup rotates and returns the newest backup
+func rotateBackup(t *testing.T, lr *LogRotator) string {
+	if err := lr.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	backups, err := lr.backups()
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("Expected a backup, got %v (%v)", backups, err)
+	}
+	return backups[len(backups)-1].path
+}
+
+func TestManifestSigned(t *testing.T) {
+	lr, publicKey := newManifestRotator(t, "signed", LogRotatorConfig{MaxSize: Unlimited, Compress: true, HashChain: true})
+
+	first := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(lr, `{"level":"info","msg":"entry %d","time":"%s"}`+"\n", i, first.Add(time.Duration(i)*time.Minute).Format(time.RFC3339))
+	}
+	backup := rotateBackup(t, lr)
+
+	manifest, err := VerifyBackup(backup, publicKey)
+	if err != nil {
+		t.Fatalf("Expected valid backup, got %v", err)
+	}
+	if manifest.File != filepath.Base(backup) || !strings.HasSuffix(manifest.File, ".gz") {
+		t.Errorf("Expected manifest of compressed backup, got %s", manifest.File)
+	}
+	stat, _ := os.Stat(backup)
+	if manifest.Size != stat.Size() || manifest.Lines != 3 {
+		t.Errorf("Unexpected size %d or line count %d", manifest.Size, manifest.Lines)
+	}
+	if manifest.FirstTimestamp == nil || !manifest.FirstTimestamp.Equal(first) ||
+		manifest.LastTimestamp == nil || !manifest.LastTimestamp.Equal(first.Add(2*time.Minute)) {
+		t.Errorf("Unexpected timestamps %v and %v", manifest.FirstTimestamp, manifest.LastTimestamp)
+	}
+
+	chain, err := readChainFile(chainPath(backup))
+	if err != nil {
+		t.Fatalf("Failed to read hash chain: %v", err)
+	}
+	if head := chain.last(); manifest.ChainHead != fmt.Sprintf("%x", head[:]) {
+		t.Errorf("Expected chain head %x in manifest, got %s", head[:], manifest.ChainHead)
+	}
+
+	// Manifests and hash chains are not listed as backups
+	backups, _ := lr.backups()
+	if len(backups) != 1 {
+		t.Errorf("Expected 1 backup, got %v", backups)
+	}
+}
+
+func TestManifestTampering(t *testing.T) {
+	lr, publicKey := newManifestRotator(t, "tampering", LogRotatorConfig{MaxSize: Unlimited})
+
+	fmt.Fprintln(lr, `time="2024-01-02T15:04:05Z" level=info msg="transfer approved"`)
+	backup := rotateBackup(t, lr)
+
+	data, _ := os.ReadFile(backup)
+	os.WriteFile(backup, bytes.Replace(data, []byte("approved"), []byte("rejected"), 1), 0644)
+
+	var merr *ManifestError
+	if _, err := VerifyBackup(backup, publicKey); !errors.As(err, &merr) || !strings.Contains(err.Error(), "SHA-256") {
+		t.Errorf("Expected SHA-256 mismatch, got %v", err)
+	}
+	os.WriteFile(backup, data, 0644)
+
+	// A manifest edited to match must fail the signature check
+	manifestData, _ := os.ReadFile(manifestPath(backup))
+	os.WriteFile(manifestPath(backup), bytes.Replace(manifestData, []byte(`"lines":1`), []byte(`"lines":2`), 1), 0644)
+	if _, err := VerifyBackup(backup, publicKey); !errors.As(err, &merr) || merr.Reason != "invalid signature" {
+		t.Errorf("Expected invalid signature, got %v", err)
+	}
+	os.WriteFile(manifestPath(backup), manifestData, 0644)
+
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+	if _, err := VerifyBackup(backup, otherKey); !errors.As(err, &merr) {
+		t.Errorf("Expected invalid signature with another key, got %v", err)
+	}
+	if _, err := VerifyBackup(backup, publicKey); err != nil {
+		t.Errorf("Expected restored backup to verify, got %v", err)
+	}
+}
+
+func TestManifestRetention(t *testing.T) {
+	lr, _ := newManifestRotator(t, "retention", LogRotatorConfig{MaxSize: Unlimited, MaxBackups: 1})
+
+	fmt.Fprintln(lr, "first")
+	oldest := rotateBackup(t, lr)
+	fmt.Fprintln(lr, "second")
+	rotateBackup(t, lr)
+
+	if fileExists(oldest) || fileExists(manifestPath(oldest)) {
+		t.Errorf("Expected backup and manifest to be removed together")
+	}
+}
+
+func TestEntryTimestamp(t *testing.T) {
+	want := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	lines := []string{
+		`{"level":"info","msg":"json","time":"2024-01-02T15:04:05Z"}`,
+		`{"@timestamp":"2024-01-02T15:04:05Z","message":"ecs"}`,
+		`{"version":"1.1","short_message":"gelf","timestamp":1704207845}`,
+		`{"timeUnixNano":"1704207845000000000","body":{"stringValue":"otlp"}}`,
+		`time="2024-01-02T15:04:05Z" level=info msg=text`,
+		`level=info msg="time=1999" time=2024-01-02T15:04:05Z`,
+	}
+	for _, line := range lines {
+		if got, ok := entryTimestamp([]byte(line)); !ok || !got.Equal(want) {
+			t.Errorf("Expected %v from %s, got %v (%v)", want, line, got, ok)
+		}
+	}
+	if _, ok := entryTimestamp([]byte("plain line")); ok {
+		t.Errorf("Expected no timestamp in a plain line")
+	}
+}
+
+func TestLoggerManifestKey(t *testing.T) {
+	dir := filepath.Join("testdata", "manifest", "logger")
+	os.RemoveAll(dir)
+	privatePath, publicPath := writeManifestKeys(t, dir)
+
+	path := filepath.Join(dir, "app.log")
+	logger, err := NewLogger(LoggerConfig{LogFile: path, ManifestKeyFile: privatePath})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("Signed entry")
+	if err := logger.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+
+	backups, _ := (&LogRotator{filePath: path}).backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+	publicKey, err := LoadManifestPublicKey(privatePath)
+	if err != nil {
+		t.Fatalf("Failed to derive public key: %v", err)
+	}
+	if _, err := VerifyBackup(backups[0].path, publicKey); err != nil {
+		t.Errorf("Expected valid backup, got %v", err)
+	}
+
+	if _, err := NewLogger(LoggerConfig{LogFile: filepath.Join(dir, "other.log"), ManifestKeyFile: publicPath}); err == nil {
+		t.Errorf("Expected error for a public key as signing key")
+	}
+}
@@ -0,0 +1,180 @@
+package panlog
+
+import (
+	"io"
+	"path"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ModuleField is the entry field that identifies the module of an entry
+const ModuleField = "module"
+
+// ModuleLogger is a named child logger. Its entries carry the module name in
+// the ModuleField field and are filtered by the module's own level, which
+// comes from LoggerConfig.ModuleLevels, SetModuleLevel or, if neither
+// matches, the parent logger's level.
+type ModuleLogger struct {
+	*logrus.Entry
+	name   string
+	parent *Logger
+}
+
+// Module returns the child logger for the named module, creating it on first
+// use. Names are dot-separated by convention (e.g. "http.server") so that
+// levels can be configured with patterns such as "http.*".
+func (l *Logger) Module(name string) *ModuleLogger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if m, ok := l.modules[name]; ok {
+		return m
+	}
+
+	// Each module has its own logrus.Logger so that it can have its own
+	// level; formatting and output are shared through the router
+	hooks := make(logrus.LevelHooks)
+	for level, levelHooks := range l.Hooks {
+		hooks[level] = append([]logrus.Hook(nil), levelHooks...)
+	}
+	ml := &logrus.Logger{
+		Out:          io.Discard,
+		Formatter:    l.router,
+		Hooks:        hooks,
+		Level:        l.moduleLevel(name),
+		ExitFunc:     l.ExitFunc,
+		ReportCaller: l.ReportCaller,
+	}
+
+	m := &ModuleLogger{
+		Entry:  logrus.NewEntry(ml).WithField(ModuleField, name),
+		name:   name,
+		parent: l,
+	}
+	l.modules[name] = m
+	return m
+}
+
+// Name returns the module name
+func (m *ModuleLogger) Name() string {
+	return m.name
+}
+
+// Module returns the child module "<name>.<sub>"
+func (m *ModuleLogger) Module(sub string) *ModuleLogger {
+	return m.parent.Module(m.name + "." + sub)
+}
+
+// GetLevel returns the module's effective level
+func (m *ModuleLogger) GetLevel() logrus.Level {
+	return m.Logger.GetLevel()
+}
+
+// IsLevelEnabled reports whether entries at level are logged by the module.
+// It is cheap enough to guard building expensive fields.
+func (m *ModuleLogger) IsLevelEnabled(level logrus.Level) bool {
+	return m.Logger.IsLevelEnabled(level)
+}
+
+// SetLevel sets the logger's own level and cancels a pending revert of
+// SetLevelFor. Modules without a module level follow it.
+func (l *Logger) SetLevel(level logrus.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cancelOverride("")
+	l.setLevel(level)
+}
+
+// setLevel sets the logger's level and updates the modules; the caller must
+// hold l.mu
+func (l *Logger) setLevel(level logrus.Level) {
+	l.Logger.SetLevel(level)
+	l.refreshModules()
+}
+
+// AddHook adds a hook to the logger and all of its modules
+func (l *Logger) AddHook(hook logrus.Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.Logger.AddHook(hook)
+	for _, m := range l.modules {
+		m.Logger.AddHook(hook)
+	}
+}
+
+// SetModuleLevel sets the level of the modules matching pattern, which is
+// either a module name or a path.Match pattern such as "http.*"
+func (l *Logger) SetModuleLevel(pattern string, level logrus.Level) error {
+	return l.SetModuleLevelFor(pattern, level, 0)
+}
+
+// ClearModuleLevel removes the level set for pattern, so that matching
+// modules fall back to less specific patterns or the logger's level
+func (l *Logger) ClearModuleLevel(pattern string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cancelOverride(pattern)
+	delete(l.moduleLevels, pattern)
+	l.refreshModules()
+}
+
+// ModuleLevels returns the configured module levels keyed by pattern
+func (l *Logger) ModuleLevels() map[string]logrus.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	levels := make(map[string]logrus.Level, len(l.moduleLevels))
+	for pattern, level := range l.moduleLevels {
+		levels[pattern] = level
+	}
+	return levels
+}
+
+// moduleLevel resolves the level of a module: an exact name match wins over
+// patterns, and longer patterns win over shorter ones. The caller must hold
+// l.mu.
+func (l *Logger) moduleLevel(name string) logrus.Level {
+	if level, ok := l.moduleLevels[name]; ok {
+		return level
+	}
+
+	patterns := make([]string, 0, len(l.moduleLevels))
+	for pattern := range l.moduleLevels {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return l.moduleLevels[pattern]
+		}
+	}
+
+	return l.GetLevel()
+}
+
+// refreshModules re-resolves the level of every module; the caller must hold
+// l.mu
+func (l *Logger) refreshModules() {
+	for name, m := range l.modules {
+		m.Logger.SetLevel(l.moduleLevel(name))
+	}
+}
+
+// parseModuleLevels parses validated module levels from the configuration
+func parseModuleLevels(levels map[string]string) map[string]logrus.Level {
+	parsed := make(map[string]logrus.Level, len(levels))
+	for pattern, name := range levels {
+		parsed[pattern] = parseLevel(name)
+	}
+	return parsed
+}
@@ -0,0 +1,601 @@
+package panlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NetworkConfig configures a network sink
+type NetworkConfig struct {
+	Network string      // tcp or udp
+	Address string      // host:port of the collector
+	TLS     *tls.Config // Enables TLS on tcp connections when set
+
+	Timeout    time.Duration // Dial and write timeout (defaults to 5s)
+	MinBackoff time.Duration // First delay between reconnect attempts (defaults to 500ms)
+	MaxBackoff time.Duration // Maximum delay between reconnect attempts (defaults to 30s)
+
+	// Disk buffer for entries written while the collector is unreachable.
+	// Spooling is disabled, and such entries are dropped, when FilePath is
+	// empty. MaxSize, MaxBackups and MaxAge bound the buffer; the oldest
+	// spooled entries are discarded first.
+	Spool LogRotatorConfig
+}
+
+// NetworkSink ships entries to a collector as newline-delimited records,
+// typically with the json format. Every entry is one line on tcp and one
+// datagram on udp.
+//
+// When the collector is unreachable, entries are appended to the spool and a
+// background goroutine reconnects with exponential backoff. Once connected,
+// the spool is replayed in order before entries are sent directly again.
+type NetworkSink struct {
+	config NetworkConfig
+	spool  *LogRotator
+
+	mu       sync.Mutex
+	conn     net.Conn
+	spooling bool // entries go to the spool until the replay caught up
+	closed   bool
+
+	// Entries per spool file, kept up to date as files are written, rotated
+	// and removed so that the spool is only read when it is replayed
+	counts map[string]int64
+
+	queued     atomic.Int64 // entries waiting in the spool
+	sent       atomic.Uint64
+	spooled    atomic.Uint64
+	dropped    atomic.Uint64
+	reconnects atomic.Uint64
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewNetworkSink creates a sink shipping entries to the collector described
+// by config. An unreachable collector is not an error: entries are spooled
+// until a connection is established.
+func NewNetworkSink(config NetworkConfig) (*NetworkSink, error) {
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		if config.TLS != nil {
+			return nil, fmt.Errorf("TLS is not supported over %s", config.Network)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported network %q", config.Network)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("collector address is required")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.MinBackoff == 0 {
+		config.MinBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+
+	s := &NetworkSink{
+		config: config,
+		counts: make(map[string]int64),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	if config.Spool.FilePath != "" {
+		if config.Spool.MaxBackups == NoBackups {
+			return nil, fmt.Errorf("spool requires backups to hold entries during replay")
+		}
+		spool, err := NewLogRotator(config.Spool)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spool: %w", err)
+		}
+		s.spool = spool
+
+		// Entries left over from a previous run are replayed first
+		if err := s.countSpooled(); err != nil {
+			spool.Close()
+			return nil, fmt.Errorf("failed to read spool: %w", err)
+		}
+		s.spooling = s.queued.Load() > 0
+	}
+
+	if conn, err := s.dial(); err == nil {
+		s.conn = conn
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	s.signal()
+	return s, nil
+}
+
+// WriteEntry implements Sink
+func (s *NetworkSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	line := p
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		line = append(append([]byte(nil), p...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("network sink is closed")
+	}
+
+	var sendErr error
+	if s.conn != nil && !s.spooling {
+		if sendErr = s.send(s.conn, line); sendErr == nil {
+			s.sent.Add(1)
+			return nil
+		}
+		s.disconnect()
+		s.signal()
+	}
+
+	if s.spool == nil {
+		s.dropped.Add(1)
+		if sendErr != nil {
+			return fmt.Errorf("failed to send to %s: %w", s.config.Address, sendErr)
+		}
+		return fmt.Errorf("not connected to %s", s.config.Address)
+	}
+
+	size := s.spool.size()
+	n, err := s.spool.Write(line)
+	if s.spool.size() < size+int64(n) {
+		// The spool rotated before the write
+		s.trackRotation()
+	}
+	if err != nil {
+		s.dropped.Add(1)
+		return fmt.Errorf("failed to spool entry: %w", err)
+	}
+	s.setCount(s.spool.filePath, s.counts[s.spool.filePath]+1)
+	s.spooled.Add(1)
+	s.spooling = true
+	s.signal()
+	return nil
+}
+
+// Close stops reconnecting and closes the connection and the spool. Spooled
+// entries are kept on disk and replayed by the next sink using the spool.
+func (s *NetworkSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	err := s.disconnect()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	if s.spool != nil {
+		err = errors.Join(err, s.spool.Close())
+	}
+	return err
+}
+
+// GetStats returns statistics about the connection and the spool
+func (s *NetworkSink) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	connected := s.conn != nil
+	s.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"network":     s.config.Network,
+		"address":     s.config.Address,
+		"connected":   connected,
+		"reconnects":  s.reconnects.Load(),
+		"sent":        s.sent.Load(),
+		"spooled":     s.spooled.Load(),
+		"dropped":     s.dropped.Load(),
+		"queue_depth": s.queued.Load(),
+	}
+	if s.spool != nil {
+		stats["spool_path"] = s.spool.filePath
+	}
+	return stats
+}
+
+// run reconnects and replays the spool until the sink is closed
+func (s *NetworkSink) run() {
+	defer s.wg.Done()
+
+	backoff := s.config.MinBackoff
+	wait := func() bool {
+		select {
+		case <-s.done:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.config.MaxBackoff {
+			backoff = s.config.MaxBackoff
+		}
+		return true
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+
+		for {
+			s.mu.Lock()
+			connected, spooling := s.conn != nil, s.spooling
+			s.mu.Unlock()
+
+			if !connected {
+				conn, err := s.dial()
+				if err != nil {
+					if !wait() {
+						return
+					}
+					continue
+				}
+
+				s.mu.Lock()
+				if s.closed {
+					s.mu.Unlock()
+					conn.Close()
+					return
+				}
+				s.conn = conn
+				s.mu.Unlock()
+				s.reconnects.Add(1)
+			}
+
+			if spooling {
+				if err := s.replay(); err != nil {
+					s.mu.Lock()
+					s.disconnect()
+					s.mu.Unlock()
+					if !wait() {
+						return
+					}
+					continue
+				}
+			}
+
+			backoff = s.config.MinBackoff
+			break
+		}
+	}
+}
+
+// replay sends the spooled entries, oldest first, until the spool is empty
+// and entries can be sent directly again
+func (s *NetworkSink) replay() error {
+	for {
+		s.mu.Lock()
+		if s.closed || s.conn == nil {
+			s.mu.Unlock()
+			return fmt.Errorf("not connected to %s", s.config.Address)
+		}
+		conn := s.conn
+
+		// Move pending entries out of the active spool file, so that
+		// entries written during the replay are appended behind them
+		if s.spool.size() > 0 {
+			err := s.spool.Rotate()
+			s.trackRotation()
+			if err != nil {
+				s.mu.Unlock()
+				return fmt.Errorf("failed to rotate spool: %w", err)
+			}
+		}
+		files, err := s.spool.backups()
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("failed to list spool: %w", err)
+		}
+		if len(files) == 0 {
+			s.spooling = false
+			s.counts = make(map[string]int64)
+			s.queued.Store(0)
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		for _, file := range files {
+			if err := s.replayFile(conn, file); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replayFile sends the entries of a spool file and removes it. If sending
+// fails, the file is rewritten with the entries that were not sent.
+func (s *NetworkSink) replayFile(conn net.Conn, file rotatedFile) error {
+	lines, err := readSpoolFile(file.path)
+	if err != nil {
+		return fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	// The queue depth counts the file until it is rewritten or removed, as
+	// spool cleanup may discard it in the meantime
+	for i, line := range lines {
+		if err := s.send(conn, line); err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if !fileExists(file.path) {
+				return fmt.Errorf("failed to replay spool: %w", err)
+			}
+			if rerr := writeSpoolFile(file, lines[i:]); rerr != nil {
+				return errors.Join(err, fmt.Errorf("failed to rewrite spool file: %w", rerr))
+			}
+			s.forget(file.path)
+			s.setCount(strings.TrimSuffix(file.path, ".gz"), int64(len(lines)-i))
+			return fmt.Errorf("failed to replay spool: %w", err)
+		}
+		s.sent.Add(1)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(file.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove spool file: %w", err)
+	}
+	s.forget(file.path)
+	return nil
+}
+
+// countSpooled reads the spool files left over from a previous run and
+// counts their entries
+func (s *NetworkSink) countSpooled() error {
+	files, err := s.spool.backups()
+	if err != nil {
+		return err
+	}
+	paths := []string{s.spool.filePath}
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+
+	for _, path := range paths {
+		lines, err := readSpoolFile(path)
+		if err != nil {
+			return err
+		}
+		s.setCount(path, int64(len(lines)))
+	}
+	return nil
+}
+
+// trackRotation moves the count of the active spool file to the backup it
+// was rotated to and drops the counts of backups removed by the cleanup; the
+// caller must hold s.mu
+func (s *NetworkSink) trackRotation() {
+	files, err := s.spool.backups()
+	if err != nil {
+		return
+	}
+
+	// The newest backup that is not counted yet is the rotated file
+	rotated := s.counts[s.spool.filePath]
+	s.setCount(s.spool.filePath, 0)
+	kept := make(map[string]bool, len(files))
+	for i := len(files) - 1; i >= 0; i-- {
+		path := files[i].path
+		kept[path] = true
+		if _, ok := s.counts[path]; !ok {
+			s.setCount(path, rotated)
+			rotated = 0
+		}
+	}
+	for path := range s.counts {
+		if !kept[path] && path != s.spool.filePath {
+			s.forget(path)
+		}
+	}
+}
+
+// setCount records the entries of a spool file and updates the queue
+// depth; the caller must hold s.mu unless the sink is not shared yet
+func (s *NetworkSink) setCount(path string, count int64) {
+	s.queued.Add(count - s.counts[path])
+	s.counts[path] = count
+}
+
+// forget drops the count of a removed spool file; the caller must hold s.mu
+func (s *NetworkSink) forget(path string) {
+	s.setCount(path, 0)
+	delete(s.counts, path)
+}
+
+// dial connects to the collector
+func (s *NetworkSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+	if s.config.TLS != nil {
+		return tls.DialWithDialer(dialer, s.config.Network, s.config.Address, s.config.TLS)
+	}
+	return dialer.Dial(s.config.Network, s.config.Address)
+}
+
+// send writes a line to conn
+func (s *NetworkSink) send(conn net.Conn, line []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	_, err := conn.Write(line)
+	return err
+}
+
+// disconnect closes the connection; the caller must hold s.mu
+func (s *NetworkSink) disconnect() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// signal wakes the background goroutine without blocking
+func (s *NetworkSink) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// readSpoolFile returns the lines of a plain or gzipped spool file
+func readSpoolFile(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	var lines [][]byte
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeSpoolFile replaces a spool file with lines, keeping its modification
+// time so that the replay order is preserved
+func writeSpoolFile(file rotatedFile, lines [][]byte) error {
+	path := strings.TrimSuffix(file.path, ".gz")
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0644); err != nil {
+		return err
+	}
+	if path != file.path {
+		if err := os.Remove(file.path); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(path, file.modTime, file.modTime)
+}
+
+// networkConfigFromOptions applies string options from config files
+func networkConfigFromOptions(config NetworkConfig, options map[string]string) (NetworkConfig, error) {
+	var caFile, serverName string
+	var useTLS, insecure bool
+
+	for key, value := range options {
+		var err error
+		switch key {
+		case "network":
+			config.Network = value
+		case "address":
+			config.Address = value
+		case "timeout":
+			config.Timeout, err = ParseDuration(value)
+		case "min_backoff":
+			config.MinBackoff, err = ParseDuration(value)
+		case "max_backoff":
+			config.MaxBackoff, err = ParseDuration(value)
+		case "tls":
+			useTLS, err = strconv.ParseBool(value)
+		case "tls_ca_file":
+			caFile, useTLS = value, true
+		case "tls_server_name":
+			serverName, useTLS = value, true
+		case "tls_insecure_skip_verify":
+			insecure, err = strconv.ParseBool(value)
+			useTLS = useTLS || insecure
+		case "spool_path":
+			config.Spool.FilePath = value
+		case "spool_max_size":
+			var size sizeValue
+			err = size.UnmarshalText([]byte(value))
+			config.Spool.MaxSize = int64(size)
+		case "spool_max_age":
+			var age durationValue
+			err = age.UnmarshalText([]byte(value))
+			config.Spool.MaxAge = time.Duration(age)
+		case "spool_max_backups":
+			var backups backupsValue
+			err = backups.UnmarshalText([]byte(value))
+			config.Spool.MaxBackups = int(backups)
+		case "spool_compress":
+			config.Spool.Compress, err = strconv.ParseBool(value)
+		default:
+			err = fmt.Errorf("unknown option")
+		}
+		if err != nil {
+			return config, fmt.Errorf("network option %s: %w", key, err)
+		}
+	}
+
+	if !useTLS {
+		return config, nil
+	}
+	if config.TLS == nil {
+		config.TLS = &tls.Config{}
+	} else {
+		config.TLS = config.TLS.Clone()
+	}
+	if serverName != "" {
+		config.TLS.ServerName = serverName
+	}
+	config.TLS.InsecureSkipVerify = config.TLS.InsecureSkipVerify || insecure
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return config, fmt.Errorf("network option tls_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return config, fmt.Errorf("network option tls_ca_file: no certificates found")
+		}
+		config.TLS.RootCAs = pool
+	}
+	return config, nil
+}
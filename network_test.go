@@ -0,0 +1,316 @@
+package panlog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newFakeCollector accepts stream connections on ln and collects the
+// received lines
+func newFakeCollector(t *testing.T, ln net.Listener) chan string {
+	lines := make(chan string, 64)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+// receiveLine waits for the next line of a fake collector
+func receiveLine(t *testing.T, lines chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for line")
+		return ""
+	}
+}
+
+// receiveMessage waits for the next JSON entry and returns its message
+func receiveMessage(t *testing.T, lines chan string) string {
+	var entry map[string]interface{}
+	line := receiveLine(t, lines)
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("Failed to parse %q: %v", line, err)
+	}
+	msg, _ := entry["msg"].(string)
+	return msg
+}
+
+func TestNetworkSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	lines := newFakeCollector(t, ln)
+
+	logger, err := NewLogger(LoggerConfig{
+		Sinks: []SinkConfig{
+			{Type: "network", Format: "json", Network: NetworkConfig{Network: "tcp", Address: ln.Addr().String()}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WithField("user", "alice").Info("First message")
+	logger.Info("Second message")
+
+	if msg := receiveMessage(t, lines); msg != "First message" {
+		t.Errorf("Expected first message, got %q", msg)
+	}
+	if msg := receiveMessage(t, lines); msg != "Second message" {
+		t.Errorf("Expected second message, got %q", msg)
+	}
+}
+
+func TestNetworkSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	sink, err := NewNetworkSink(NetworkConfig{Network: "udp", Address: conn.LocalAddr().String()})
+	if err != nil {
+		t.Fatalf("Failed to create network sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.WriteEntry(logrus.NewEntry(logrus.New()), []byte(`{"msg":"datagram"}`)); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read datagram: %v", err)
+	}
+	if got := string(buf[:n]); got != "{\"msg\":\"datagram\"}\n" {
+		t.Errorf("Expected one NDJSON record per datagram, got %q", got)
+	}
+}
+
+func TestNetworkSinkTLS(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	lines := newFakeCollector(t, ln)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	sink, err := NewNetworkSink(NetworkConfig{
+		Network: "tcp",
+		Address: ln.Addr().String(),
+		TLS:     &tls.Config{RootCAs: pool, ServerName: "example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create network sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.WriteEntry(logrus.NewEntry(logrus.New()), []byte("{\"msg\":\"secure\"}\n")); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+	if line := receiveLine(t, lines); line != `{"msg":"secure"}` {
+		t.Errorf("Expected entry over TLS, got %q", line)
+	}
+}
+
+func TestNetworkSinkSpoolReplay(t *testing.T) {
+	// Reserve an address with no collector behind it
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	spoolPath := filepath.Join("testdata", "spool", "network.log")
+	sink, err := NewNetworkSink(NetworkConfig{
+		Network:    "tcp",
+		Address:    address,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+		Spool:      LogRotatorConfig{FilePath: spoolPath, MaxSize: 64},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create network sink: %v", err)
+	}
+	defer sink.Close()
+
+	entry := logrus.NewEntry(logrus.New())
+	for i := 0; i < 5; i++ {
+		if err := sink.WriteEntry(entry, []byte(fmt.Sprintf("entry %d\n", i))); err != nil {
+			t.Fatalf("Failed to spool entry: %v", err)
+		}
+	}
+
+	stats := sink.GetStats()
+	if stats["queue_depth"] != int64(5) {
+		t.Errorf("Expected queue depth 5, got %v", stats["queue_depth"])
+	}
+	if stats["connected"] != false {
+		t.Errorf("Expected sink to be disconnected")
+	}
+
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+	lines := newFakeCollector(t, ln)
+
+	for i := 5; i < 10; i++ {
+		if err := sink.WriteEntry(entry, []byte(fmt.Sprintf("entry %d\n", i))); err != nil {
+			t.Fatalf("Failed to write entry: %v", err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		if line := receiveLine(t, lines); line != fmt.Sprintf("entry %d", i) {
+			t.Fatalf("Expected entry %d in order, got %q", i, line)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.GetStats()["queue_depth"] != int64(0) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected empty queue after replay, got %v", sink.GetStats()["queue_depth"])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNetworkSinkWithoutSpool(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	sink, err := NewNetworkSink(NetworkConfig{Network: "tcp", Address: address, MinBackoff: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create network sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.WriteEntry(logrus.NewEntry(logrus.New()), []byte("lost\n")); err == nil {
+		t.Errorf("Expected error while the collector is unreachable")
+	}
+	if dropped := sink.GetStats()["dropped"]; dropped != uint64(1) {
+		t.Errorf("Expected 1 dropped entry, got %v", dropped)
+	}
+}
+
+func TestNetworkSinkSpoolCleanup(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	dir := filepath.Join("testdata", "spool-cleanup")
+	os.RemoveAll(dir)
+	config := NetworkConfig{
+		Network:    "tcp",
+		Address:    address,
+		MinBackoff: time.Hour,
+		Spool:      LogRotatorConfig{FilePath: filepath.Join(dir, "network.log"), MaxSize: 16, MaxBackups: 1},
+	}
+	sink, err := NewNetworkSink(config)
+	if err != nil {
+		t.Fatalf("Failed to create network sink: %v", err)
+	}
+
+	entry := logrus.NewEntry(logrus.New())
+	for i := 0; i < 20; i++ {
+		if err := sink.WriteEntry(entry, []byte(fmt.Sprintf("entry %02d\n", i))); err != nil {
+			t.Fatalf("Failed to spool entry: %v", err)
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "network*.log"))
+	if err != nil {
+		t.Fatalf("Failed to list spool: %v", err)
+	}
+	var spooled int64
+	for _, path := range paths {
+		lines, err := readSpoolFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		spooled += int64(len(lines))
+	}
+	if depth := sink.GetStats()["queue_depth"]; depth != spooled || spooled >= 20 {
+		t.Errorf("Expected queue depth to match the %d entries left after cleanup, got %v", spooled, depth)
+	}
+	sink.Close()
+
+	// A new sink counts the entries left in the spool
+	sink, err = NewNetworkSink(config)
+	if err != nil {
+		t.Fatalf("Failed to create network sink: %v", err)
+	}
+	defer sink.Close()
+	if depth := sink.GetStats()["queue_depth"]; depth != spooled {
+		t.Errorf("Expected queue depth %d after reopening the spool, got %v", spooled, depth)
+	}
+}
+
+func TestNetworkConfigSpoolSentinels(t *testing.T) {
+	config, err := networkConfigFromOptions(NetworkConfig{}, map[string]string{
+		"spool_max_size":    "unlimited",
+		"spool_max_age":     "unlimited",
+		"spool_max_backups": "unlimited",
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	if config.Spool.MaxSize != Unlimited || config.Spool.MaxAge != Unlimited || config.Spool.MaxBackups != Unlimited {
+		t.Errorf("Expected unlimited spool limits, got %+v", config.Spool)
+	}
+
+	config, err = networkConfigFromOptions(NetworkConfig{}, map[string]string{"spool_max_backups": "none"})
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	if config.Spool.MaxBackups != NoBackups {
+		t.Errorf("Expected NoBackups, got %d", config.Spool.MaxBackups)
+	}
+
+	if _, err := networkConfigFromOptions(NetworkConfig{}, map[string]string{"spool_max_backups": "some"}); err == nil {
+		t.Errorf("Expected error for invalid spool_max_backups")
+	}
+}
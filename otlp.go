@@ -0,0 +1,336 @@
+package panlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Entry fields carrying the trace context of an entry. The OTLP formatter
+// moves them to the traceId, spanId and flags of the log record.
+const (
+	TraceIDField    = "trace_id"
+	SpanIDField     = "span_id"
+	TraceFlagsField = "trace_flags"
+)
+
+// DefaultOTLPEndpoint is the logs endpoint of a local OpenTelemetry collector
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/logs"
+
+// OTLPFormatter renders entries as OpenTelemetry log records in the OTLP/JSON
+// encoding, one record per line. The message becomes the body, the level the
+// severity and the fields the attributes.
+type OTLPFormatter struct{}
+
+// otlpRecord is an OTLP LogRecord
+type otlpRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes,omitempty"`
+	TraceID              string          `json:"traceId,omitempty"`
+	SpanID               string          `json:"spanId,omitempty"`
+	Flags                uint32          `json:"flags,omitempty"`
+}
+
+// otlpAttribute is an OTLP KeyValue
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an OTLP AnyValue; exactly one field is set
+type otlpValue struct {
+	StringValue *string          `json:"stringValue,omitempty"`
+	BoolValue   *bool            `json:"boolValue,omitempty"`
+	IntValue    *string          `json:"intValue,omitempty"`
+	DoubleValue *float64         `json:"doubleValue,omitempty"`
+	BytesValue  *[]byte          `json:"bytesValue,omitempty"` // base64 encoded
+	ArrayValue  *otlpArrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlistValue `json:"kvlistValue,omitempty"`
+}
+
+// otlpArrayValue is an OTLP ArrayValue
+type otlpArrayValue struct {
+	Values []otlpValue `json:"values"`
+}
+
+// otlpKvlistValue is an OTLP KeyValueList
+type otlpKvlistValue struct {
+	Values []otlpAttribute `json:"values"`
+}
+
+// Format implements logrus.Formatter
+func (f *OTLPFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	number, text := otlpSeverity(entry.Level)
+	record := otlpRecord{
+		TimeUnixNano:         strconv.FormatInt(entry.Time.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(entry.Time.UnixNano(), 10),
+		SeverityNumber:       number,
+		SeverityText:         text,
+		Body:                 otlpAnyValue(entry.Message),
+	}
+
+	data := make(logrus.Fields, len(entry.Data)+3)
+	for key, value := range entry.Data {
+		data[key] = value
+	}
+	if traceID, ok := otlpHexID(data[TraceIDField], 16); ok {
+		record.TraceID = traceID
+		delete(data, TraceIDField)
+	}
+	if spanID, ok := otlpHexID(data[SpanIDField], 8); ok {
+		record.SpanID = spanID
+		delete(data, SpanIDField)
+	}
+	if flags, err := strconv.ParseUint(fmt.Sprint(data[TraceFlagsField]), 16, 8); err == nil {
+		record.Flags = uint32(flags)
+		delete(data, TraceFlagsField)
+	}
+	if entry.HasCaller() {
+		data["code.filepath"] = entry.Caller.File
+		data["code.lineno"] = entry.Caller.Line
+		data["code.function"] = entry.Caller.Function
+	}
+	record.Attributes = otlpAttributes(data)
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OTLP record: %w", err)
+	}
+	return append(encoded, '\n'), nil
+}
+
+// otlpSeverity maps a logrus level to an OTLP severity number and text
+func otlpSeverity(level logrus.Level) (int, string) {
+	switch level {
+	case logrus.TraceLevel:
+		return 1, "TRACE"
+	case logrus.DebugLevel:
+		return 5, "DEBUG"
+	case logrus.InfoLevel:
+		return 9, "INFO"
+	case logrus.WarnLevel:
+		return 13, "WARN"
+	case logrus.ErrorLevel:
+		return 17, "ERROR"
+	case logrus.FatalLevel:
+		return 21, "FATAL"
+	default:
+		return 24, "PANIC" // FATAL4
+	}
+}
+
+// otlpHexID returns a trace or span id of size bytes as lowercase hex
+func otlpHexID(value interface{}, size int) (string, bool) {
+	var id []byte
+	switch v := value.(type) {
+	case string:
+		decoded, err := hex.DecodeString(v)
+		if err != nil {
+			return "", false
+		}
+		id = decoded
+	case []byte:
+		id = v
+	default:
+		return "", false
+	}
+	if len(id) != size || strings.Trim(string(id), "\x00") == "" {
+		return "", false
+	}
+	return hex.EncodeToString(id), true
+}
+
+// otlpAttributes converts fields to attributes sorted by key
+func otlpAttributes(fields map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]otlpAttribute, len(keys))
+	for i, key := range keys {
+		attributes[i] = otlpAttribute{Key: key, Value: otlpAnyValue(fields[key])}
+	}
+	return attributes
+}
+
+// otlpAnyValue converts a field value to an AnyValue
+func otlpAnyValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case nil:
+		return otlpValue{}
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case []byte:
+		return otlpValue{BytesValue: &v}
+	case error, fmt.Stringer:
+		// fmt.Sprint prints typed nil pointers whose methods panic as <nil>
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	case map[string]interface{}:
+		return otlpValue{KvlistValue: &otlpKvlistValue{Values: otlpAttributes(v)}}
+	case logrus.Fields:
+		return otlpValue{KvlistValue: &otlpKvlistValue{Values: otlpAttributes(v)}}
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := strconv.FormatInt(rv.Int(), 10)
+		return otlpValue{IntValue: &s}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := strconv.FormatUint(rv.Uint(), 10)
+		return otlpValue{IntValue: &s}
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return otlpValue{DoubleValue: &f}
+	case reflect.Slice, reflect.Array:
+		values := make([]otlpValue, rv.Len())
+		for i := range values {
+			values[i] = otlpAnyValue(rv.Index(i).Interface())
+		}
+		return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+	}
+
+	s := fmt.Sprint(value)
+	return otlpValue{StringValue: &s}
+}
+
+// OTLPConfig configures an OTLP sink. Batching, retries and the dead-letter
+// file work as for the http sink; URL defaults to DefaultOTLPEndpoint and
+// Encoding is ignored.
+type OTLPConfig struct {
+	HTTPConfig
+
+	// Resource attributes, e.g. {"deployment.environment": "prod"}.
+	// service.name defaults to the logger's ServiceName or the program name.
+	ResourceAttributes map[string]string
+
+	ScopeName string // Instrumentation scope name (defaults to "panlog")
+}
+
+// OTLPSink exports entries to an OpenTelemetry collector with OTLP/HTTP
+// JSON. The sink renders entries with an OTLPFormatter, so its Format
+// setting is ignored.
+type OTLPSink struct {
+	formatter *OTLPFormatter
+	http      *HTTPSink
+}
+
+// NewOTLPSink creates a sink exporting to config.URL
+func NewOTLPSink(config OTLPConfig) (*OTLPSink, error) {
+	if config.URL == "" {
+		config.URL = DefaultOTLPEndpoint
+	}
+	if config.ScopeName == "" {
+		config.ScopeName = "panlog"
+	}
+	config.Encoding = HTTPJSONArray
+
+	resource := make(map[string]interface{}, len(config.ResourceAttributes)+1)
+	for key, value := range config.ResourceAttributes {
+		resource[key] = value
+	}
+	if _, ok := resource["service.name"]; !ok {
+		resource["service.name"] = filepath.Base(os.Args[0])
+	}
+
+	resourceJSON, err := json.Marshal(map[string]interface{}{"attributes": otlpAttributes(resource)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OTLP resource: %w", err)
+	}
+	scopeJSON, err := json.Marshal(map[string]string{"name": config.ScopeName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OTLP scope: %w", err)
+	}
+
+	// Batches are sent as the logRecords of a single resource and scope
+	prefix := `{"resourceLogs":[{"resource":` + string(resourceJSON) +
+		`,"scopeLogs":[{"scope":` + string(scopeJSON) + `,"logRecords":[`
+	suffix := `]}]}]}`
+
+	httpSink, err := newHTTPSink(config.HTTPConfig, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	return &OTLPSink{formatter: &OTLPFormatter{}, http: httpSink}, nil
+}
+
+// WriteEntry implements Sink
+func (s *OTLPSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	record, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	return s.http.WriteEntry(entry, record)
+}
+
+// Close exports the pending records and releases the sink
+func (s *OTLPSink) Close() error {
+	return s.http.Close()
+}
+
+// GetStats returns export statistics
+func (s *OTLPSink) GetStats() map[string]interface{} {
+	return s.http.GetStats()
+}
+
+// withService sets the service.name and service.version resource attributes
+// from the logger's configuration unless they are set explicitly
+func (c OTLPConfig) withService(name, version string) OTLPConfig {
+	attributes := make(map[string]string, len(c.ResourceAttributes)+2)
+	for key, value := range c.ResourceAttributes {
+		attributes[key] = value
+	}
+	if _, ok := attributes["service.name"]; !ok && name != "" {
+		attributes["service.name"] = name
+	}
+	if _, ok := attributes["service.version"]; !ok && version != "" {
+		attributes["service.version"] = version
+	}
+	c.ResourceAttributes = attributes
+	return c
+}
+
+// otlpConfigFromOptions applies string options from config files. Resource
+// attributes are given as "resource.<key>"; the http options apply too.
+func otlpConfigFromOptions(config OTLPConfig, options map[string]string) (OTLPConfig, error) {
+	httpOptions := make(map[string]string, len(options))
+	for key, value := range options {
+		switch {
+		case strings.HasPrefix(key, "resource."):
+			attributes := make(map[string]string, len(config.ResourceAttributes)+1)
+			for name, v := range config.ResourceAttributes {
+				attributes[name] = v
+			}
+			attributes[strings.TrimPrefix(key, "resource.")] = value
+			config.ResourceAttributes = attributes
+		case key == "scope_name":
+			config.ScopeName = value
+		default:
+			httpOptions[key] = value
+		}
+	}
+
+	httpConfig, err := httpConfigFromOptions(config.HTTPConfig, httpOptions)
+	if err != nil {
+		return config, fmt.Errorf("otlp: %w", err)
+	}
+	config.HTTPConfig = httpConfig
+	return config, nil
+}
@@ -0,0 +1,182 @@
+package panlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// otlpExport is the OTLP/JSON export request as decoded by the test receiver
+type otlpExport struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []otlpRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+// otlpAttributeValue returns the string form of an attribute
+func otlpAttributeValue(attributes []otlpAttribute, key string) interface{} {
+	for _, attribute := range attributes {
+		if attribute.Key != key {
+			continue
+		}
+		switch v := attribute.Value; {
+		case v.StringValue != nil:
+			return *v.StringValue
+		case v.IntValue != nil:
+			return *v.IntValue
+		case v.BoolValue != nil:
+			return *v.BoolValue
+		case v.DoubleValue != nil:
+			return *v.DoubleValue
+		}
+	}
+	return nil
+}
+
+func TestOTLPSink(t *testing.T) {
+	requests := make(chan otlpExport, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		var export otlpExport
+		if err := json.Unmarshal(body, &export); err != nil {
+			t.Errorf("Failed to parse export %s: %v", body, err)
+		}
+		requests <- export
+	}))
+	defer server.Close()
+
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel:       "debug",
+		ServiceName:    "checkout",
+		ServiceVersion: "2.0.1",
+		Sinks: []SinkConfig{{
+			Type: "otlp",
+			OTLP: OTLPConfig{
+				HTTPConfig:         HTTPConfig{URL: server.URL + "/v1/logs", BatchSize: 2},
+				ResourceAttributes: map[string]string{"deployment.environment": "test"},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WithFields(logrus.Fields{
+		TraceIDField:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanIDField:     "00f067aa0ba902b7",
+		TraceFlagsField: "01",
+		"user":          "alice",
+		"attempt":       3,
+	}).Warn("Payment declined")
+	logger.Debug("Retrying")
+
+	var export otlpExport
+	select {
+	case export = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for export")
+	}
+
+	if len(export.ResourceLogs) != 1 || len(export.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("Expected one resource and scope, got %+v", export)
+	}
+	resource := export.ResourceLogs[0].Resource.Attributes
+	for key, value := range map[string]string{"service.name": "checkout", "service.version": "2.0.1", "deployment.environment": "test"} {
+		if got := otlpAttributeValue(resource, key); got != value {
+			t.Errorf("Expected resource %s=%q, got %v", key, value, got)
+		}
+	}
+
+	scope := export.ResourceLogs[0].ScopeLogs[0]
+	if scope.Scope.Name != "panlog" || len(scope.LogRecords) != 2 {
+		t.Fatalf("Expected 2 records in the panlog scope, got %+v", scope)
+	}
+
+	record := scope.LogRecords[0]
+	if record.SeverityNumber != 13 || record.SeverityText != "WARN" {
+		t.Errorf("Expected WARN severity 13, got %s %d", record.SeverityText, record.SeverityNumber)
+	}
+	if record.Body.StringValue == nil || *record.Body.StringValue != "Payment declined" {
+		t.Errorf("Expected message body, got %+v", record.Body)
+	}
+	if record.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || record.SpanID != "00f067aa0ba902b7" || record.Flags != 1 {
+		t.Errorf("Expected trace context, got %q %q %d", record.TraceID, record.SpanID, record.Flags)
+	}
+	if got := otlpAttributeValue(record.Attributes, "user"); got != "alice" {
+		t.Errorf("Expected user attribute, got %v", got)
+	}
+	if got := otlpAttributeValue(record.Attributes, "attempt"); got != "3" {
+		t.Errorf("Expected attempt as intValue, got %v", got)
+	}
+	if got := otlpAttributeValue(record.Attributes, TraceIDField); got != nil {
+		t.Errorf("Expected trace_id to be moved out of the attributes, got %v", got)
+	}
+
+	if debug := scope.LogRecords[1]; debug.SeverityNumber != 5 || debug.TraceID != "" {
+		t.Errorf("Expected DEBUG record without trace context, got %+v", debug)
+	}
+}
+
+func TestOTLPFormatterInvalidTraceID(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New()).WithField(TraceIDField, "not-hex")
+	entry.Level = logrus.InfoLevel
+
+	data, err := (&OTLPFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+	var record otlpRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse record: %v", err)
+	}
+	if record.TraceID != "" || otlpAttributeValue(record.Attributes, TraceIDField) != "not-hex" {
+		t.Errorf("Expected invalid trace id to stay an attribute, got %+v", record)
+	}
+}
+
+func TestOTLPFormatterValues(t *testing.T) {
+	var stringer *nilStringer
+	entry := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{
+		"stringer": fmt.Stringer(stringer),
+		"error":    error(stringer),
+		"payload":  []byte("raw"),
+	})
+	entry.Level = logrus.InfoLevel
+
+	data, err := (&OTLPFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+	if !strings.Contains(string(data), `{"key":"payload","value":{"bytesValue":"cmF3"}}`) {
+		t.Errorf("Expected bytes to be a base64 bytesValue, got %s", data)
+	}
+
+	var record otlpRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse record: %v", err)
+	}
+	for _, key := range []string{"stringer", "error"} {
+		if value := otlpAttributeValue(record.Attributes, key); value != "<nil>" {
+			t.Errorf("Expected typed nil %s to be <nil>, got %v", key, value)
+		}
+	}
+}
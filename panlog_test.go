@@ -1,10 +1,15 @@
 package panlog
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestNewLogRotator(t *testing.T) {
@@ -151,6 +156,49 @@ func TestLogRotatorCompression(t *testing.T) {
 	}
 }
 
+func TestLogRotatorCompressionFailure(t *testing.T) {
+	path := filepath.Join("testdata", "compress_failure.log")
+	os.RemoveAll(path + ".gz")
+	if err := os.WriteFile(path, []byte("kept\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	// A directory in the way of the compressed file makes compression fail
+	if err := os.MkdirAll(path+".gz", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := (&LogRotator{}).compressFile(path); err == nil {
+		t.Fatal("Expected compression to fail")
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "kept\n" {
+		t.Errorf("Expected the original file to be kept, got %q (%v)", content, err)
+	}
+}
+
+func TestLogRotatorRotateFailure(t *testing.T) {
+	path := filepath.Join("testdata", "rotate_failure.log")
+	os.Remove(path)
+	lr, err := NewLogRotator(LogRotatorConfig{FilePath: path})
+	if err != nil {
+		t.Fatalf("Failed to create log rotator: %v", err)
+	}
+	defer lr.Close()
+
+	// Renaming a file that was removed underneath the rotator fails
+	lr.Write([]byte("lost\n"))
+	os.Remove(path)
+	if err := lr.Rotate(); err == nil {
+		t.Fatal("Expected rotation to fail")
+	}
+
+	if _, err := lr.Write([]byte("kept\n")); err != nil {
+		t.Fatalf("Expected writes to continue after a failed rotation, got %v", err)
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "kept\n" {
+		t.Errorf("Expected the live file to be reopened, got %q (%v)", content, err)
+	}
+}
+
 func TestNewLogger(t *testing.T) {
 	// Test with valid configuration
 	config := LoggerConfig{
@@ -339,6 +387,428 @@ func TestManualRotation(t *testing.T) {
 	}
 }
 
+func TestLoggerCounters(t *testing.T) {
+	config := LoggerConfig{
+		LogLevel:      "info",
+		LogFile:       "testdata/counters_test.log",
+		MaxSize:       1024 * 1024,
+		MaxAge:        time.Hour,
+		MaxBackups:    3,
+		ConsoleOutput: false,
+		CountField:    "module",
+	}
+
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Debug("Filtered out by level")
+	logger.Info("Info message")
+	logger.WithField("module", "db").Error("Database error")
+	logger.WithField("module", "db").Error("Another database error")
+	logger.WithField("module", "auth").Warn("Auth warning")
+
+	snapshot := logger.Counters()
+	if snapshot.Total != 4 {
+		t.Errorf("Expected 4 counted entries, got %d", snapshot.Total)
+	}
+	if snapshot.Levels[logrus.DebugLevel] != 0 {
+		t.Errorf("Expected no debug entries, got %d", snapshot.Levels[logrus.DebugLevel])
+	}
+	if snapshot.Levels[logrus.ErrorLevel] != 2 {
+		t.Errorf("Expected 2 error entries, got %d", snapshot.Levels[logrus.ErrorLevel])
+	}
+	if snapshot.Fields["db"][logrus.ErrorLevel] != 2 {
+		t.Errorf("Expected 2 db error entries, got %d", snapshot.Fields["db"][logrus.ErrorLevel])
+	}
+	if snapshot.Fields["auth"][logrus.WarnLevel] != 1 {
+		t.Errorf("Expected 1 auth warn entry, got %d", snapshot.Fields["auth"][logrus.WarnLevel])
+	}
+
+	if _, ok := logger.GetStats()["counters"]; !ok {
+		t.Error("Expected counters in stats")
+	}
+
+	reset := logger.ResetCounters()
+	if reset.Total != 4 {
+		t.Errorf("Expected reset snapshot to hold 4 entries, got %d", reset.Total)
+	}
+	if total := logger.Counters().Total; total != 0 {
+		t.Errorf("Expected counters to be zero after reset, got %d", total)
+	}
+}
+
+func TestLoggerConfigValidate(t *testing.T) {
+	config := LoggerConfig{
+		LogLevel:   "verbose",
+		MaxSize:    -5,
+		MaxAge:     Unlimited,
+		MaxBackups: NoBackups,
+		Compress:   true,
+	}
+
+	err := config.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, fe := range verr.Errors {
+		fields[fe.Field] = true
+	}
+	for _, field := range []string{"LogLevel", "MaxSize", "Compress"} {
+		if !fields[field] {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+	if fields["MaxAge"] || fields["MaxBackups"] {
+		t.Errorf("Expected sentinels to be accepted, got %v", err)
+	}
+
+	if _, err := NewLogger(LoggerConfig{LogLevel: "verbose"}); err == nil {
+		t.Error("Expected NewLogger to reject an unknown level")
+	}
+	if _, err := NewLogRotator(LogRotatorConfig{FilePath: "testdata/invalid.log", MaxBackups: -3}); err == nil {
+		t.Error("Expected NewLogRotator to reject a negative MaxBackups")
+	}
+}
+
+func TestLogRotatorRetentionSentinels(t *testing.T) {
+	// Unlimited size never rotates
+	unlimited, err := NewLogRotator(LogRotatorConfig{
+		FilePath: "testdata/unlimited_test.log",
+		MaxSize:  Unlimited,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create log rotator: %v", err)
+	}
+	defer unlimited.Close()
+
+	for i := 0; i < 100; i++ {
+		if _, err := unlimited.Write([]byte("unlimited log message\n")); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	if matches, _ := filepath.Glob("testdata/unlimited_test-*.log*"); len(matches) != 0 {
+		t.Errorf("Expected no rotated files, found %v", matches)
+	}
+
+	// NoBackups discards rotated files
+	none, err := NewLogRotator(LogRotatorConfig{
+		FilePath:   "testdata/nobackups_test.log",
+		MaxSize:    50,
+		MaxBackups: NoBackups,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create log rotator: %v", err)
+	}
+	defer none.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := none.Write([]byte("This message is long enough to trigger a rotation\n")); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	if matches, _ := filepath.Glob("testdata/nobackups_test-*.log*"); len(matches) != 0 {
+		t.Errorf("Expected no backups, found %v", matches)
+	}
+}
+
+func TestLoggerReconfigure(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel:   "info",
+		LogFile:    "testdata/reconfigure_old.log",
+		MaxSize:    1024 * 1024,
+		MaxBackups: 3,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Debug("Dropped debug message")
+	logger.Info("Old file message")
+
+	err = logger.Reconfigure(LoggerConfig{
+		LogLevel:   "debug",
+		LogFile:    "testdata/reconfigure_new.log",
+		MaxSize:    2048,
+		MaxBackups: 2,
+		JSONFormat: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to reconfigure logger: %v", err)
+	}
+
+	logger.Debug("New file message")
+
+	old, err := os.ReadFile("testdata/reconfigure_old.log")
+	if err != nil {
+		t.Fatalf("Failed to read old log file: %v", err)
+	}
+	if !strings.Contains(string(old), "Old file message") || strings.Contains(string(old), "New file message") {
+		t.Errorf("Unexpected old log file content: %s", old)
+	}
+
+	current, err := os.ReadFile("testdata/reconfigure_new.log")
+	if err != nil {
+		t.Fatalf("Failed to read new log file: %v", err)
+	}
+	if !strings.Contains(string(current), `"msg":"New file message"`) {
+		t.Errorf("Expected JSON debug entry in new log file, got: %s", current)
+	}
+
+	stats := logger.GetStats()["rotator"].(map[string]interface{})
+	if stats["max_size"] != int64(2048) || stats["max_backups"] != 2 {
+		t.Errorf("Expected new rotation limits, got %v", stats)
+	}
+
+	// Invalid configurations leave the logger untouched
+	if err := logger.Reconfigure(LoggerConfig{LogLevel: "loud"}); err == nil {
+		t.Error("Expected an error for an invalid configuration")
+	}
+	if logger.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected level to stay debug, got %v", logger.GetLevel())
+	}
+
+	// So do configurations failing after the log files were opened
+	if err := os.WriteFile("testdata/reconfigure_blocked", nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	err = logger.Reconfigure(LoggerConfig{
+		LogFile:    "testdata/reconfigure_new.log",
+		MaxSize:    4096,
+		MaxBackups: 1,
+		ErrorLog:   ErrorLogConfig{Enabled: true, LogRotatorConfig: LogRotatorConfig{FilePath: "testdata/reconfigure_error.log"}},
+		Sinks:      []SinkConfig{{Name: "blocked", Type: "file", File: LogRotatorConfig{FilePath: "testdata/reconfigure_blocked/sink.log"}}},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a sink that cannot be opened")
+	}
+	stats = logger.GetStats()["rotator"].(map[string]interface{})
+	if stats["max_size"] != int64(2048) || stats["max_backups"] != 2 {
+		t.Errorf("Expected rotation limits to be unchanged, got %v", stats)
+	}
+	if _, ok := logger.GetStats()["error_log"]; ok {
+		t.Error("Expected no error log after a failed Reconfigure")
+	}
+}
+
+func TestModuleLevels(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel: "info",
+		LogFile:  "testdata/module_test.log",
+		ModuleLevels: map[string]string{
+			"db":     "warn",
+			"http.*": "debug",
+			"*":      "error",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	levels := map[string]logrus.Level{
+		"db":          logrus.WarnLevel,
+		"http.server": logrus.DebugLevel,
+		"auth":        logrus.ErrorLevel,
+	}
+	for name, want := range levels {
+		if got := logger.Module(name).GetLevel(); got != want {
+			t.Errorf("Module %q: expected level %v, got %v", name, want, got)
+		}
+	}
+
+	if logger.Module("db") != logger.Module("db") {
+		t.Error("Expected Module to return the same logger for the same name")
+	}
+	if logger.Module("http").Module("client").Name() != "http.client" {
+		t.Error("Expected nested module name http.client")
+	}
+
+	// Module levels are independent of the logger's level
+	logger.Module("http.server").Debug("Module debug message")
+	logger.Module("db").Info("Dropped db info message")
+	logger.Debug("Dropped logger debug message")
+
+	content, err := os.ReadFile("testdata/module_test.log")
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(content), "module=http.server") {
+		t.Errorf("Expected module debug entry with module field, got: %s", content)
+	}
+	if strings.Contains(string(content), "Dropped") {
+		t.Errorf("Expected filtered entries to be dropped, got: %s", content)
+	}
+
+	// Modules without a module level follow the logger's level
+	logger.ClearModuleLevel("*")
+	if got := logger.Module("auth").GetLevel(); got != logrus.InfoLevel {
+		t.Errorf("Expected auth to inherit info, got %v", got)
+	}
+	logger.SetLevel(logrus.TraceLevel)
+	if !logger.Module("auth").IsLevelEnabled(logrus.TraceLevel) {
+		t.Error("Expected auth to follow the logger's new level")
+	}
+
+	if err := logger.SetModuleLevel("auth", logrus.PanicLevel); err != nil {
+		t.Fatalf("Failed to set module level: %v", err)
+	}
+	if logger.Module("auth").IsLevelEnabled(logrus.ErrorLevel) {
+		t.Error("Expected auth errors to be disabled")
+	}
+
+	if _, err := NewLogger(LoggerConfig{ModuleLevels: map[string]string{"[": "info", "db": "loud"}}); err == nil {
+		t.Error("Expected invalid module levels to be rejected")
+	}
+}
+
+func TestOutputLevelsAndFormats(t *testing.T) {
+	// A custom sink stands in for the console
+	var console bytes.Buffer
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel:   "debug",
+		LogFile:    "testdata/output_levels_test.log",
+		FileLevel:  "warn",
+		FileFormat: "json",
+		Sinks: []SinkConfig{
+			{Name: "console", Level: "debug", Format: "text", Sink: NewWriterSink(&console)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Debug("Debug message")
+	logger.Warn("Warn message")
+
+	content, err := os.ReadFile("testdata/output_levels_test.log")
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(content), "Debug message") {
+		t.Errorf("Expected debug entries to be filtered from the file, got: %s", content)
+	}
+	if !strings.Contains(string(content), `"msg":"Warn message"`) {
+		t.Errorf("Expected JSON warn entry in the file, got: %s", content)
+	}
+
+	lines := strings.Split(strings.TrimSpace(console.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `msg="Debug message"`) || strings.Contains(lines[1], "{") {
+		t.Errorf("Expected two text entries on the console, got: %q", console.String())
+	}
+
+	if _, err := NewLogger(LoggerConfig{FileFormat: "xml", ConsoleLevel: "loud"}); err == nil {
+		t.Error("Expected unknown format and level to be rejected")
+	}
+}
+
+func TestSetOutputAndFormatter(t *testing.T) {
+	path := "testdata/set_output_test.log"
+	os.Remove(path)
+	config := LoggerConfig{LogFile: path, Format: "text"}
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	var console bytes.Buffer
+	logger.SetOutput(&console)
+	logger.Info("Redirected")
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.Info("As JSON")
+
+	config.LogLevel = "debug"
+	if err := logger.Reconfigure(config); err != nil {
+		t.Fatalf("Failed to reconfigure: %v", err)
+	}
+	logger.Debug("After reconfigure")
+	logger.SetFormatter(nil)
+	logger.Info("Configured format")
+
+	lines := strings.Split(strings.TrimSpace(console.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], `msg=Redirected`) ||
+		!strings.HasPrefix(lines[1], "{") || !strings.HasPrefix(lines[2], "{") || !strings.Contains(lines[3], `msg="Configured format"`) {
+		t.Errorf("Expected the console output in the buffer, got: %q", console.String())
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if n := strings.Count(string(content), "\n"); n != 4 || !strings.Contains(string(content), `"msg":"As JSON"`) {
+		t.Errorf("Expected the file output to be kept and reformatted, got: %s", content)
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel:   "info",
+		LogFile:    "testdata/split.log",
+		MaxBackups: 1,
+		ErrorLog: ErrorLogConfig{
+			Enabled: true,
+			LogRotatorConfig: LogRotatorConfig{
+				MaxSize:    200,
+				MaxBackups: 3,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("Info message")
+	logger.Module("db").Warn("Warn message")
+	logger.Error("Error message")
+
+	content, err := os.ReadFile("testdata/split.error.log")
+	if err != nil {
+		t.Fatalf("Failed to read error log: %v", err)
+	}
+	if strings.Contains(string(content), "Info message") ||
+		!strings.Contains(string(content), "Warn message") ||
+		!strings.Contains(string(content), "Error message") {
+		t.Errorf("Unexpected error log content: %s", content)
+	}
+
+	main, err := os.ReadFile("testdata/split.log")
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Count(string(main), "\n") != 3 {
+		t.Errorf("Expected all 3 entries in the main log, got: %s", main)
+	}
+
+	// The error log rotates on its own limits, and main log retention
+	// leaves its backups alone
+	for i := 0; i < 5; i++ {
+		logger.Errorf("Error message %d that fills up the error log quickly", i)
+	}
+	if err := logger.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	if matches, _ := filepath.Glob("testdata/split.error-*.log"); len(matches) == 0 {
+		t.Error("Expected rotated error log files")
+	}
+
+	if _, ok := logger.GetStats()["error_log"]; !ok {
+		t.Error("Expected error log in stats")
+	}
+
+	if _, err := NewLogger(LoggerConfig{ErrorLog: ErrorLogConfig{Enabled: true}}); err == nil {
+		t.Error("Expected an error log without a path to be rejected")
+	}
+}
+
 // Cleanup function to remove test files
 func TestMain(m *testing.M) {
 	// Create testdata directory
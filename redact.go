@@ -0,0 +1,389 @@
+package panlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redaction actions
+const (
+	RedactMask = "mask" // Replace with the mask text
+	RedactHash = "hash" // Replace with a salted SHA-256 hash, so equal values stay correlatable
+	RedactDrop = "drop" // Remove the field, or the match from the message
+)
+
+// RedactionRule selects sensitive data by field name, by a value pattern or
+// by a built-in value pattern; exactly one of Field, Pattern and Builtin is
+// set
+type RedactionRule struct {
+	Field   string // Field name pattern, e.g. "password" or "*token*" (case-insensitive)
+	Pattern string // Regular expression matched against messages and values
+	Builtin string // Built-in value pattern: pan, email or jwt
+	Action  string // RedactMask (default), RedactHash or RedactDrop
+}
+
+// RedactionConfig configures the redaction of entries before they are
+// formatted. Field rules apply to the whole value of matching fields, at any
+// depth of nested maps; value rules apply to the matching parts of the
+// message, string values and error strings.
+type RedactionConfig struct {
+	Rules []RedactionRule
+	Mask  string // Replacement of masked data (defaults to "[REDACTED]")
+	Salt  string // Salt of hashed data; required by the hash action
+}
+
+// builtinRedactions are the value patterns available to RedactionRule.Builtin
+var builtinRedactions = map[string]struct {
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}{
+	// Card numbers of 13 to 19 digits, optionally grouped, passing the Luhn check
+	"pan":   {regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), luhnValid},
+	"email": {regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), nil},
+	"jwt":   {regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]*\.eyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*`), nil},
+}
+
+// DefaultRedactionRules masks passwords, secrets, tokens, credentials and
+// card numbers, email addresses and JWTs in any value
+func DefaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		{Field: "*password*"},
+		{Field: "*passwd*"},
+		{Field: "*secret*"},
+		{Field: "*token*"},
+		{Field: "*api_key*"},
+		{Field: "*apikey*"},
+		{Field: "authorization"},
+		{Field: "cookie"},
+		{Builtin: "pan"},
+		{Builtin: "email"},
+		{Builtin: "jwt"},
+	}
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every invalid field, or nil if the configuration is usable
+func (c RedactionConfig) Validate() error {
+	var v validator
+	hashed := false
+
+	for i, rule := range c.Rules {
+		field := fmt.Sprintf("Rules[%d]", i)
+
+		set := 0
+		for _, s := range []string{rule.Field, rule.Pattern, rule.Builtin} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			v.add(field, rule, "exactly one of Field, Pattern and Builtin is required")
+		}
+
+		if _, err := path.Match(rule.Field, ""); err != nil {
+			v.add(field+".Field", rule.Field, "invalid field pattern")
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			v.add(field+".Pattern", rule.Pattern, "invalid regular expression")
+		}
+		if _, ok := builtinRedactions[rule.Builtin]; rule.Builtin != "" && !ok {
+			v.add(field+".Builtin", rule.Builtin, "unknown built-in pattern (want one of pan, email, jwt)")
+		}
+
+		switch rule.Action {
+		case "", RedactMask, RedactDrop:
+		case RedactHash:
+			hashed = true
+		default:
+			v.add(field+".Action", rule.Action, "unknown action (want mask, hash or drop)")
+		}
+	}
+
+	if hashed && c.Salt == "" {
+		v.add("Salt", c.Salt, "is required by the hash action")
+	}
+
+	return v.err()
+}
+
+// redactor applies validated redaction rules
+type redactor struct {
+	fields []redactionRule
+	values []redactionRule
+	mask   string
+	salt   string
+}
+
+// redactionRule is a compiled RedactionRule
+type redactionRule struct {
+	field   string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+	action  string
+}
+
+// defaultMask replaces masked data unless RedactionConfig.Mask is set
+const defaultMask = "[REDACTED]"
+
+// newRedactor compiles a validated configuration; it returns nil when there
+// is nothing to redact
+func newRedactor(config RedactionConfig) *redactor {
+	if len(config.Rules) == 0 {
+		return nil
+	}
+
+	r := &redactor{mask: config.Mask, salt: config.Salt}
+	if r.mask == "" {
+		r.mask = defaultMask
+	}
+
+	for _, rule := range config.Rules {
+		compiled := redactionRule{action: rule.Action}
+		if compiled.action == "" {
+			compiled.action = RedactMask
+		}
+		switch {
+		case rule.Field != "":
+			compiled.field = strings.ToLower(rule.Field)
+			r.fields = append(r.fields, compiled)
+		case rule.Pattern != "":
+			compiled.pattern = regexp.MustCompile(rule.Pattern)
+			r.values = append(r.values, compiled)
+		default:
+			builtin := builtinRedactions[rule.Builtin]
+			compiled.pattern, compiled.valid = builtin.pattern, builtin.valid
+			r.values = append(r.values, compiled)
+		}
+	}
+	return r
+}
+
+// redactMessage redacts the matches of the value rules in a message
+func (r *redactor) redactMessage(msg string) string {
+	redacted, _, _ := r.redactString(msg)
+	return redacted
+}
+
+// redactFields returns a redacted copy of fields, or fields itself if
+// nothing was redacted
+func (r *redactor) redactFields(fields map[string]interface{}) (map[string]interface{}, bool) {
+	var out map[string]interface{}
+	for key, value := range fields {
+		redacted, drop, changed := r.redactField(key, value)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				out[k] = v
+			}
+		}
+		if drop {
+			delete(out, key)
+		} else {
+			out[key] = redacted
+		}
+	}
+	if out == nil {
+		return fields, false
+	}
+	return out, true
+}
+
+// redactField redacts a field value, by name first, then by value
+func (r *redactor) redactField(key string, value interface{}) (interface{}, bool, bool) {
+	name := strings.ToLower(key)
+	for _, rule := range r.fields {
+		if ok, _ := path.Match(rule.field, name); ok {
+			switch rule.action {
+			case RedactDrop:
+				return nil, true, true
+			case RedactHash:
+				return r.hash(fmt.Sprint(value)), false, true
+			default:
+				return r.mask, false, true
+			}
+		}
+	}
+	return r.redactValue(value)
+}
+
+// redactValue redacts the matches of the value rules in a value, descending
+// into maps and slices. It never modifies value itself.
+func (r *redactor) redactValue(value interface{}) (interface{}, bool, bool) {
+	switch v := value.(type) {
+	case nil, bool:
+		return value, false, false
+	case string:
+		redacted, drop, changed := r.redactString(v)
+		return redacted, drop, changed
+	case error:
+		redacted, drop, changed := r.redactString(v.Error())
+		return redacted, drop, changed
+	case logrus.Fields:
+		redacted, changed := r.redactFields(v)
+		return logrus.Fields(redacted), false, changed
+	case map[string]interface{}:
+		redacted, changed := r.redactFields(v)
+		return redacted, false, changed
+	case map[string]string:
+		generic := make(map[string]interface{}, len(v))
+		for key, s := range v {
+			generic[key] = s
+		}
+		if redacted, changed := r.redactFields(generic); changed {
+			return redacted, false, true
+		}
+		return value, false, false
+	case []interface{}:
+		var out []interface{}
+		for i, item := range v {
+			redacted, drop, changed := r.redactValue(item)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]interface{}(nil), v...)
+			}
+			if drop {
+				redacted = nil
+			}
+			out[i] = redacted
+		}
+		if out == nil {
+			return value, false, false
+		}
+		return out, false, true
+	case []string:
+		var out []string
+		for i, item := range v {
+			redacted, drop, changed := r.redactString(item)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]string(nil), v...)
+			}
+			if drop {
+				redacted = ""
+			}
+			out[i] = redacted
+		}
+		if out == nil {
+			return value, false, false
+		}
+		return out, false, true
+	}
+
+	if len(r.values) == 0 {
+		return value, false, false
+	}
+
+	// Numbers may be card numbers and other types may render sensitive
+	// data, so check their string form
+	redacted, drop, changed := r.redactString(fmt.Sprint(value))
+	if !changed {
+		return value, false, false
+	}
+	return redacted, drop, true
+}
+
+// redactString applies the value rules to s. It reports whether a rule with
+// the drop action matched, in which case the field should be removed.
+func (r *redactor) redactString(s string) (string, bool, bool) {
+	var drop, changed bool
+	for _, rule := range r.values {
+		s = rule.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			changed = true
+			switch rule.action {
+			case RedactDrop:
+				drop = true
+				return ""
+			case RedactHash:
+				return r.hash(match)
+			default:
+				return r.mask
+			}
+		})
+	}
+	return s, drop, changed
+}
+
+// hash returns a salted hash of s, so that equal values can be correlated
+// without being revealed
+func (r *redactor) hash(s string) string {
+	sum := sha256.Sum256([]byte(r.salt + s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// redactionHook is a logrus hook redacting entries before they are
+// formatted. It must be the first hook so that no other hook sees the
+// unredacted entry.
+type redactionHook struct {
+	mu       sync.RWMutex
+	redactor *redactor
+}
+
+// Levels implements logrus.Hook
+func (h *redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *redactionHook) Fire(entry *logrus.Entry) error {
+	h.mu.RLock()
+	r := h.redactor
+	h.mu.RUnlock()
+
+	if r == nil {
+		return nil
+	}
+
+	entry.Message = r.redactMessage(entry.Message)
+	// The entry's Data is a copy owned by this entry, but nested maps are
+	// shared with the caller and are copied by redactFields when redacted
+	if redacted, changed := r.redactFields(entry.Data); changed {
+		entry.Data = redacted
+	}
+	return nil
+}
+
+// swap replaces the redaction rules
+func (h *redactionHook) swap(r *redactor) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.redactor = r
+}
@@ -0,0 +1,144 @@
+package panlog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// router renders entries and writes them to the logger's outputs. logrus
+// only knows a single formatter and writer, so the router is installed as
+// the logrus Formatter (with Out set to io.Discard) and does both steps
+// itself for every output. This lets each output have its own level and
+// formatter, and lets the outputs be replaced atomically at runtime.
+type router struct {
+	mu        sync.RWMutex
+	outputs   []*output
+	formatter logrus.Formatter // Replaces the outputs' formatters when set
+	filters   []entryFilter
+}
+
+// entryFilter decides whether the router writes an entry. Filters run after
+// the hooks, so they see the entry as it will be formatted.
+type entryFilter interface {
+	allow(entry *logrus.Entry) bool
+}
+
+// newRouter creates a router writing entries to outputs
+func newRouter(outputs []*output) *router {
+	return &router{
+		outputs: outputs,
+	}
+}
+
+// Format implements logrus.Formatter. It writes the entry to every output
+// whose level allows it and returns no bytes for logrus to write.
+func (r *router) Format(entry *logrus.Entry) ([]byte, error) {
+	for _, filter := range r.filters {
+		if !filter.allow(entry) {
+			return nil, nil
+		}
+	}
+
+	r.write(entry)
+	return nil, nil
+}
+
+// write writes an entry to the outputs, bypassing the filters
+func (r *router) write(entry *logrus.Entry) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Formatters render into entry.Buffer when it is set, which would make
+	// every output after the first one see the previous outputs' bytes
+	buffer := entry.Buffer
+	defer func() {
+		entry.Buffer = buffer
+	}()
+
+	for _, out := range r.outputs {
+		if entry.Level > out.level {
+			continue
+		}
+
+		formatter := out.formatter
+		if r.formatter != nil {
+			formatter = r.formatter
+		}
+
+		entry.Buffer = nil
+		serialized, err := formatter.Format(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to format entry for %s, %v\n", out.name, err)
+			continue
+		}
+
+		out.write(entry, serialized)
+	}
+}
+
+// swap replaces the outputs and returns the previous ones. Once swap
+// returns, no entry is written to the previous outputs anymore.
+func (r *router) swap(outputs []*output) []*output {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.outputs
+	r.outputs = outputs
+	return previous
+}
+
+// setFormatter makes every output use formatter, or its own formatter again
+// if formatter is nil
+func (r *router) setFormatter(formatter logrus.Formatter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.formatter = formatter
+}
+
+// current returns the outputs entries are written to
+func (r *router) current() []*output {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.outputs
+}
+
+// ticker calls a function on every tick of a background goroutine. Filters
+// use it to end their intervals and write their summary entries.
+type ticker struct {
+	quit chan struct{}
+	done chan struct{}
+}
+
+// startTicker calls fn every interval until the ticker is stopped
+func startTicker(interval time.Duration, fn func()) *ticker {
+	t := &ticker{quit: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-tick.C:
+				fn()
+			case <-t.quit:
+				fn()
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// stop calls fn a last time and stops the ticker
+func (t *ticker) stop() {
+	close(t.quit)
+	<-t.done
+}
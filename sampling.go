@@ -0,0 +1,238 @@
+package panlog
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields of the summary entries written at the end of every sampling
+// interval in which entries were sampled out
+const (
+	SampledMessageField = "sampled_message"
+	SampledOutField     = "sampled_out"
+)
+
+// SamplingRate is the sampling rate of a level: the first First entries
+// with the same message in an interval are written, then every
+// Thereafter-th one. A zero First disables sampling; a zero Thereafter
+// drops every entry after the first First.
+type SamplingRate struct {
+	First      int
+	Thereafter int
+}
+
+// SamplingConfig configures the sampling of repeated messages. Entries are
+// sampled per level and message; at the end of each interval a summary entry
+// reports how many entries with each message were sampled out.
+//
+// Sampling applies to every output, including the error log. Hooks such as
+// the level counters still see every entry.
+type SamplingConfig struct {
+	Interval time.Duration // Sampling period (defaults to one second)
+
+	First      int // Rate of levels missing from Levels, see SamplingRate
+	Thereafter int
+
+	// Per-level rates keyed by level name, e.g. {"error": {}} to never
+	// sample errors
+	Levels map[string]SamplingRate
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every invalid field, or nil if the configuration is usable
+func (c SamplingConfig) Validate() error {
+	var v validator
+
+	if c.Interval < 0 {
+		v.add("Interval", c.Interval, "must not be negative")
+	}
+	if c.First < 0 {
+		v.add("First", c.First, "must not be negative")
+	}
+	if c.Thereafter < 0 {
+		v.add("Thereafter", c.Thereafter, "must not be negative")
+	}
+	for name, rate := range c.Levels {
+		field := fmt.Sprintf("Levels[%q]", name)
+		if _, err := logrus.ParseLevel(name); err != nil {
+			v.add(field, name, "unknown log level")
+		}
+		if rate.First < 0 {
+			v.add(field+".First", rate.First, "must not be negative")
+		}
+		if rate.Thereafter < 0 {
+			v.add(field+".Thereafter", rate.Thereafter, "must not be negative")
+		}
+	}
+
+	return v.err()
+}
+
+// rates returns the rate of every level
+func (c SamplingConfig) rates() []SamplingRate {
+	rates := make([]SamplingRate, len(logrus.AllLevels))
+	for _, level := range logrus.AllLevels {
+		rates[level] = SamplingRate{First: c.First, Thereafter: c.Thereafter}
+	}
+	for name, rate := range c.Levels {
+		if level, err := logrus.ParseLevel(name); err == nil {
+			rates[level] = rate
+		}
+	}
+	return rates
+}
+
+// enabled reports whether any level is sampled
+func (c SamplingConfig) enabled() bool {
+	for _, rate := range c.rates() {
+		if rate.First > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// samplingKey identifies the entries sampled together
+type samplingKey struct {
+	level   logrus.Level
+	message string
+}
+
+// samplingCount counts the entries of a key in the current interval
+type samplingCount struct {
+	seen    int
+	dropped int
+	logger  *logrus.Logger // Logger of the summary entry
+}
+
+// sampler is the router filter that samples entries. A background goroutine
+// ends each interval, writing the summary entries with emit.
+type sampler struct {
+	emit func(entry *logrus.Entry)
+
+	mu     sync.Mutex // guards the fields below
+	rates  []SamplingRate
+	counts map[samplingKey]*samplingCount
+	total  uint64
+
+	ctl    sync.Mutex // serializes swap and close
+	ticker *ticker
+}
+
+// newSampler creates a sampler writing summary entries with emit
+func newSampler(config SamplingConfig, emit func(entry *logrus.Entry)) *sampler {
+	s := &sampler{emit: emit}
+	s.swap(config)
+	return s
+}
+
+// allow implements entryFilter
+func (s *sampler) allow(entry *logrus.Entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if int(entry.Level) >= len(s.rates) {
+		return true
+	}
+	rate := s.rates[entry.Level]
+	if rate.First <= 0 {
+		return true
+	}
+
+	key := samplingKey{level: entry.Level, message: entry.Message}
+	count, ok := s.counts[key]
+	if !ok {
+		count = &samplingCount{logger: entry.Logger}
+		s.counts[key] = count
+	}
+	count.seen++
+
+	if count.seen <= rate.First {
+		return true
+	}
+	if rate.Thereafter > 0 && (count.seen-rate.First)%rate.Thereafter == 0 {
+		return true
+	}
+	count.dropped++
+	s.total++
+	return false
+}
+
+// flush ends the current interval and writes a summary entry for every
+// message that was sampled out
+func (s *sampler) flush() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = make(map[samplingKey]*samplingCount)
+	s.mu.Unlock()
+
+	keys := make([]samplingKey, 0, len(counts))
+	for key, count := range counts {
+		if count.dropped > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].level != keys[j].level {
+			return keys[i].level < keys[j].level
+		}
+		return keys[i].message < keys[j].message
+	})
+
+	for _, key := range keys {
+		count := counts[key]
+		entry := logrus.NewEntry(count.logger).WithFields(logrus.Fields{
+			SampledMessageField: key.message,
+			SampledOutField:     count.dropped,
+		})
+		entry.Time = time.Now()
+		entry.Level = key.level
+		entry.Message = "Sampled out entries"
+		s.emit(entry)
+	}
+}
+
+// swap ends the current interval and applies a new configuration
+func (s *sampler) swap(config SamplingConfig) {
+	s.ctl.Lock()
+	defer s.ctl.Unlock()
+
+	if s.ticker != nil {
+		s.ticker.stop()
+		s.ticker = nil
+	}
+
+	s.mu.Lock()
+	s.rates = config.rates()
+	s.counts = make(map[samplingKey]*samplingCount)
+	s.mu.Unlock()
+
+	if !config.enabled() {
+		return
+	}
+	interval := config.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	s.ticker = startTicker(interval, s.flush)
+}
+
+// close writes the summary of the current interval and stops sampling
+func (s *sampler) close() {
+	s.swap(SamplingConfig{})
+}
+
+// stats returns sampling statistics
+func (s *sampler) stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"sampled_out": s.total,
+		"messages":    len(s.counts),
+	}
+}
@@ -0,0 +1,164 @@
+package panlog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countMessages counts the entries per message
+func countMessages(entries []map[string]interface{}) map[string]int {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		msg, _ := entry["msg"].(string)
+		counts[msg]++
+	}
+	return counts
+}
+
+func TestSamplingFirstThereafter(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{Sampling: SamplingConfig{Interval: time.Hour, First: 3, Thereafter: 5}})
+
+	for i := 0; i < 20; i++ {
+		logger.Info("Cache miss")
+	}
+	logger.Info("Other message")
+	logger.sampler.flush()
+
+	entries := bufferEntries(t, out)
+	counts := countMessages(entries)
+	// Entries 1-3 are written, then every 5th one: 8, 13 and 18
+	if counts["Cache miss"] != 6 {
+		t.Errorf("Expected 6 sampled entries, got %d", counts["Cache miss"])
+	}
+	if counts["Other message"] != 1 {
+		t.Errorf("Expected other message to be written, got %d", counts["Other message"])
+	}
+
+	summary := entries[len(entries)-1]
+	if summary["msg"] != "Sampled out entries" || summary[SampledMessageField] != "Cache miss" ||
+		summary[SampledOutField] != float64(14) || summary["level"] != "info" {
+		t.Errorf("Unexpected summary entry %v", summary)
+	}
+	if stats := logger.GetStats()["sampling"].(map[string]interface{}); stats["sampled_out"] != uint64(14) {
+		t.Errorf("Expected 14 sampled out entries in stats, got %v", stats["sampled_out"])
+	}
+}
+
+func TestSamplingPerLevel(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{Sampling: SamplingConfig{
+		Interval: time.Hour,
+		First:    1,
+		Levels: map[string]SamplingRate{
+			"debug": {First: 2, Thereafter: 2},
+			"error": {},
+		},
+	}})
+
+	for i := 0; i < 10; i++ {
+		logger.Debug("Polling")
+		logger.Info("Request")
+		logger.Error("Failure")
+	}
+
+	counts := countMessages(bufferEntries(t, out))
+	if counts["Polling"] != 6 {
+		t.Errorf("Expected 6 debug entries, got %d", counts["Polling"])
+	}
+	if counts["Request"] != 1 {
+		t.Errorf("Expected 1 info entry, got %d", counts["Request"])
+	}
+	if counts["Failure"] != 10 {
+		t.Errorf("Expected errors not to be sampled, got %d", counts["Failure"])
+	}
+	// Hooks see every entry
+	if n := logger.Counters().Total; n != 30 {
+		t.Errorf("Expected counters to count 30 entries, got %d", n)
+	}
+}
+
+func TestSamplingInterval(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{Sampling: SamplingConfig{Interval: 50 * time.Millisecond, First: 1}})
+
+	logger.Info("Tick")
+	logger.Info("Tick")
+	time.Sleep(200 * time.Millisecond)
+	logger.Info("Tick")
+	logger.Close()
+
+	counts := countMessages(bufferEntries(t, out))
+	if counts["Tick"] != 2 {
+		t.Errorf("Expected the count to restart after the interval, got %d entries", counts["Tick"])
+	}
+	if counts["Sampled out entries"] != 1 {
+		t.Errorf("Expected 1 summary entry, got %d", counts["Sampled out entries"])
+	}
+}
+
+func TestSamplingReconfigure(t *testing.T) {
+	logger, out := newBufferLogger(t, LoggerConfig{Sampling: SamplingConfig{Interval: time.Hour, First: 1}})
+
+	logger.Info("Repeated")
+	logger.Info("Repeated")
+	if err := logger.Reconfigure(LoggerConfig{
+		LogLevel: "debug",
+		Format:   "json",
+		Sinks:    []SinkConfig{{Name: "buffer", Sink: NewWriterSink(out)}},
+	}); err != nil {
+		t.Fatalf("Failed to reconfigure: %v", err)
+	}
+	logger.Info("Repeated")
+	logger.Info("Repeated")
+
+	counts := countMessages(bufferEntries(t, out))
+	if counts["Repeated"] != 3 || counts["Sampled out entries"] != 1 {
+		t.Errorf("Expected summary on reconfigure and no sampling after, got %v", counts)
+	}
+}
+
+func TestSamplingValidation(t *testing.T) {
+	_, err := NewLogger(LoggerConfig{Sampling: SamplingConfig{
+		First:  -1,
+		Levels: map[string]SamplingRate{"loud": {First: 1}},
+	}})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	for _, field := range []string{"Sampling.First", `Sampling.Levels["loud"]`} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected error for %s, got %v", field, err)
+		}
+	}
+}
+
+func TestLoadConfigSampling(t *testing.T) {
+	path := filepath.Join("testdata", "sampling.toml")
+	content := `[sampling]
+interval = "10s"
+first = 100
+thereafter = 10
+
+[sampling.levels.error]
+first = 0
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	sampling := config.Sampling
+	if sampling.Interval != 10*time.Second || sampling.First != 100 || sampling.Thereafter != 10 {
+		t.Errorf("Unexpected sampling config %+v", sampling)
+	}
+	if rate, ok := sampling.Levels["error"]; !ok || rate.First != 0 {
+		t.Errorf("Expected error level rate, got %+v", sampling.Levels)
+	}
+}
@@ -0,0 +1,516 @@
+package panlog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is a destination for log entries. Implementations must be safe for
+// concurrent use.
+type Sink interface {
+	// WriteEntry delivers a single entry; p is the entry rendered by the
+	// sink's formatter. It is called while logging, one output after the
+	// other, so it should not block; sinks that may block, e.g. on the
+	// network, should be given a queue with SinkConfig.QueueSize.
+	WriteEntry(entry *logrus.Entry, p []byte) error
+
+	// Close flushes and releases the sink
+	Close() error
+}
+
+// SinkConfig configures one of the logger's outputs
+type SinkConfig struct {
+	Name     string            // Name used in stats and errors (defaults to Type)
+	Type     string            // Sink type: file, stdout, stderr, syslog, journald, network, http, gelf, otlp or a registered type
+	Level    string            // Minimum level written to the sink (defaults to all entries)
+	Format   string            // Format of the entries (defaults to the logger's Format)
+	File     LogRotatorConfig  // Settings for the file type
+	Syslog   SyslogConfig      // Settings for the syslog type
+	Journald JournaldConfig    // Settings for the journald type
+	Network  NetworkConfig     // Settings for the network type
+	HTTP     HTTPConfig        // Settings for the http type
+	GELF     GELFConfig        // Settings for the gelf type
+	OTLP     OTLPConfig        // Settings for the otlp type
+	Options  map[string]string // Settings from config files and for registered sink types
+	Sink     Sink              // Custom sink; takes precedence over Type
+
+	// Entries buffered for delivery by a background goroutine, so that a
+	// slow sink does not hold up logging and the other outputs. Entries are
+	// dropped while the queue is full. The syslog, network and gelf types
+	// default to 1000; other sinks are written synchronously unless set.
+	QueueSize int
+}
+
+// defaultQueueSize is the queue size of the network-backed sink types
+const defaultQueueSize = 1000
+
+// defaultFlushTimeout bounds the wait for queued fatal and panic entries,
+// like the default write timeout of the network-backed sink types
+const defaultFlushTimeout = 5 * time.Second
+
+// queueSize returns the queue size of the sink, or zero to write it
+// synchronously
+func (c SinkConfig) queueSize() int {
+	if c.QueueSize > 0 || c.Sink != nil {
+		return c.QueueSize
+	}
+	switch c.Type {
+	case "syslog", "network", "gelf":
+		return defaultQueueSize
+	}
+	return 0
+}
+
+// SinkFactory creates a sink from its configuration
+type SinkFactory func(config SinkConfig) (Sink, error)
+
+var (
+	sinkTypesMu sync.RWMutex
+	sinkTypes   = map[string]SinkFactory{
+		"file": func(config SinkConfig) (Sink, error) {
+			return NewLogRotator(config.File)
+		},
+		"stdout": func(config SinkConfig) (Sink, error) {
+			return NewWriterSink(os.Stdout), nil
+		},
+		"stderr": func(config SinkConfig) (Sink, error) {
+			return NewWriterSink(os.Stderr), nil
+		},
+		"syslog": func(config SinkConfig) (Sink, error) {
+			syslogConfig, err := syslogConfigFromOptions(config.Syslog, config.Options)
+			if err != nil {
+				return nil, err
+			}
+			return NewSyslogSink(syslogConfig)
+		},
+		"journald": func(config SinkConfig) (Sink, error) {
+			journaldConfig, err := journaldConfigFromOptions(config.Journald, config.Options)
+			if err != nil {
+				return nil, err
+			}
+			return NewJournaldSink(journaldConfig)
+		},
+		"network": func(config SinkConfig) (Sink, error) {
+			networkConfig, err := networkConfigFromOptions(config.Network, config.Options)
+			if err != nil {
+				return nil, err
+			}
+			return NewNetworkSink(networkConfig)
+		},
+		"http": func(config SinkConfig) (Sink, error) {
+			httpConfig, err := httpConfigFromOptions(config.HTTP, config.Options)
+			if err != nil {
+				return nil, err
+			}
+			return NewHTTPSink(httpConfig)
+		},
+		"gelf": func(config SinkConfig) (Sink, error) {
+			gelfConfig, err := gelfConfigFromOptions(config.GELF, config.Options)
+			if err != nil {
+				return nil, err
+			}
+			return NewGELFSink(gelfConfig)
+		},
+		"otlp": func(config SinkConfig) (Sink, error) {
+			otlpConfig, err := otlpConfigFromOptions(config.OTLP, config.Options)
+			if err != nil {
+				return nil, err
+			}
+			return NewOTLPSink(otlpConfig)
+		},
+	}
+)
+
+// RegisterSinkType makes a sink type available to SinkConfig.Type. It
+// replaces any sink type registered under the same name.
+func RegisterSinkType(name string, factory SinkFactory) {
+	sinkTypesMu.Lock()
+	defer sinkTypesMu.Unlock()
+
+	sinkTypes[name] = factory
+}
+
+// sinkFactory returns the factory of a registered sink type
+func sinkFactory(name string) (SinkFactory, bool) {
+	sinkTypesMu.RLock()
+	defer sinkTypesMu.RUnlock()
+
+	factory, ok := sinkTypes[name]
+	return factory, ok
+}
+
+// name returns the sink name, defaulting to the type or position
+func (c SinkConfig) name(index int) string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.Sink == nil && c.Type != "":
+		return c.Type
+	}
+	return fmt.Sprintf("sink%d", index)
+}
+
+// Validate checks the sink configuration and returns a *ValidationError
+// listing every invalid field, or nil if the configuration is usable
+func (c SinkConfig) Validate() error {
+	var v validator
+
+	v.level("Level", c.Level)
+	v.format("Format", c.Format)
+	if c.QueueSize < 0 {
+		v.add("QueueSize", c.QueueSize, "must not be negative")
+	}
+
+	if c.Sink == nil {
+		switch _, ok := sinkFactory(c.Type); {
+		case c.Type == "":
+			v.add("Type", c.Type, "sink type or custom sink is required")
+		case !ok:
+			v.add("Type", c.Type, "unknown sink type")
+		case c.Type == "file":
+			v.nested("File", c.File.Validate())
+		}
+	}
+
+	return v.err()
+}
+
+// open creates the sink
+func (c SinkConfig) open() (Sink, error) {
+	if c.Sink != nil {
+		return c.Sink, nil
+	}
+
+	factory, ok := sinkFactory(c.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type %q", c.Type)
+	}
+	return factory(c)
+}
+
+// writerSink adapts an io.Writer to the Sink interface
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink writing rendered entries to w. Writes are
+// serialized; closing the sink does not close w.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+// WriteEntry implements Sink
+func (s *writerSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.w.Write(p)
+	return err
+}
+
+// Close implements Sink
+func (s *writerSink) Close() error {
+	return nil
+}
+
+// WriteEntry implements Sink
+func (lr *LogRotator) WriteEntry(entry *logrus.Entry, p []byte) error {
+	_, err := lr.Write(p)
+	return err
+}
+
+// output is a sink together with its level, formatter and delivery stats
+type output struct {
+	name      string
+	level     logrus.Level
+	formatter logrus.Formatter
+	sink      Sink
+	console   bool // Whether this is the console output, see Logger.SetOutput
+
+	queue        chan queuedEntry // Entries waiting for delivery, nil for synchronous outputs
+	queueDone    chan struct{}    // Closed once the queue is drained
+	flushTimeout time.Duration    // Maximum wait for the delivery of fatal and panic entries
+
+	written      atomic.Uint64
+	failed       atomic.Uint64
+	queueDropped atomic.Uint64
+
+	mu          sync.Mutex // guards the fields below
+	lastErr     error
+	lastErrTime time.Time
+	failing     bool
+}
+
+// newOutput creates an output for sink
+func newOutput(name, level string, formatter logrus.Formatter, sink Sink) *output {
+	return &output{
+		name:      name,
+		level:     outputLevel(level),
+		formatter: formatter,
+		sink:      sink,
+	}
+}
+
+// queuedEntry is an entry waiting in the queue of an output
+type queuedEntry struct {
+	entry *logrus.Entry
+	p     []byte
+	done  chan struct{} // Closed once delivered, for callers waiting for it
+}
+
+// startQueue makes the output deliver entries from a queue of size entries
+// in a background goroutine
+func (o *output) startQueue(size int) {
+	o.queue = make(chan queuedEntry, size)
+	o.queueDone = make(chan struct{})
+	o.flushTimeout = defaultFlushTimeout
+	go func() {
+		defer close(o.queueDone)
+
+		for queued := range o.queue {
+			o.deliver(queued.entry, queued.p)
+			if queued.done != nil {
+				close(queued.done)
+			}
+		}
+	}()
+}
+
+// write delivers an entry to the sink, or queues it if the output has a
+// queue. Errors and panics are recorded and never reach the other outputs.
+func (o *output) write(entry *logrus.Entry, p []byte) {
+	if o.queue == nil {
+		o.deliver(entry, p)
+		return
+	}
+
+	// The entry and buffer are reused once logging returns
+	queued := queuedEntry{entry: copyEntry(entry), p: append([]byte(nil), p...)}
+	if entry.Level <= logrus.FatalLevel {
+		// The process exits right after fatal entries, so wait for them, but
+		// not for a full queue or a hung sink
+		queued.done = make(chan struct{})
+		timer := time.NewTimer(o.flushTimeout)
+		defer timer.Stop()
+
+		select {
+		case o.queue <- queued:
+		case <-timer.C:
+			o.queueDropped.Add(1)
+			o.fail(fmt.Errorf("queue is still full after %s, %s entry dropped", o.flushTimeout, entry.Level))
+			return
+		}
+		select {
+		case <-queued.done:
+		case <-timer.C:
+			o.fail(fmt.Errorf("%s entry not delivered within %s", entry.Level, o.flushTimeout))
+		}
+		return
+	}
+
+	select {
+	case o.queue <- queued:
+	default:
+		o.queueDropped.Add(1)
+		o.fail(fmt.Errorf("queue of %d entries is full, entry dropped", cap(o.queue)))
+	}
+}
+
+// deliver writes an entry to the sink and records the outcome
+func (o *output) deliver(entry *logrus.Entry, p []byte) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("sink panicked: %v", r)
+			}
+		}()
+		return o.sink.WriteEntry(entry, p)
+	}()
+
+	if err == nil {
+		o.written.Add(1)
+		o.mu.Lock()
+		o.failing = false
+		o.mu.Unlock()
+		return
+	}
+	o.fail(err)
+}
+
+// fail records an entry that could not be delivered
+func (o *output) fail(err error) {
+	o.failed.Add(1)
+	o.mu.Lock()
+	report := !o.failing
+	o.failing = true
+	o.lastErr = err
+	o.lastErrTime = time.Now()
+	o.mu.Unlock()
+
+	// Report only the first of a series of failures to avoid flooding stderr
+	if report {
+		fmt.Fprintf(os.Stderr, "Failed to write to %s, %v\n", o.name, err)
+	}
+}
+
+// drain delivers the queued entries and stops the queue. No entry may be
+// written to the output afterwards.
+func (o *output) drain() {
+	if o.queue == nil {
+		return
+	}
+	close(o.queue)
+	<-o.queueDone
+}
+
+// copyEntry copies the parts of an entry that sinks read
+func copyEntry(entry *logrus.Entry) *logrus.Entry {
+	copied := *entry
+	copied.Buffer = nil
+	copied.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		copied.Data[key] = value
+	}
+	return &copied
+}
+
+// stats returns delivery statistics, merged with the sink's own statistics
+func (o *output) stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"level":   o.level.String(),
+		"written": o.written.Load(),
+		"failed":  o.failed.Load(),
+	}
+
+	if o.queue != nil {
+		stats["queue_length"] = len(o.queue)
+		stats["queue_dropped"] = o.queueDropped.Load()
+	}
+
+	o.mu.Lock()
+	if o.lastErr != nil {
+		stats["last_error"] = o.lastErr.Error()
+		stats["last_error_time"] = o.lastErrTime
+	}
+	o.mu.Unlock()
+
+	if s, ok := o.sink.(interface{ GetStats() map[string]interface{} }); ok {
+		for key, value := range s.GetStats() {
+			if _, exists := stats[key]; !exists {
+				stats[key] = value
+			}
+		}
+	}
+
+	return stats
+}
+
+// openOutputs creates the outputs of config: the LogFile rotator, the error
+// log, the console and every configured sink. The rotators are passed in so
+// that Reconfigure can keep the open log files.
+func openOutputs(config LoggerConfig, rotator, errorRotator *LogRotator) ([]*output, error) {
+	var outputs []*output
+	if rotator != nil {
+		formatter := config.formatter(config.FileFormat, false)
+		outputs = append(outputs, newOutput("file", config.FileLevel, formatter, rotator))
+	}
+	if errorRotator != nil {
+		outputs = append(outputs, errorLogOutput(config, errorRotator))
+	}
+
+	if config.ConsoleOutput || (rotator == nil && len(config.Sinks) == 0) {
+		formatter := config.formatter(config.ConsoleFormat, isTerminal(os.Stdout))
+		out := newOutput("console", config.ConsoleLevel, formatter, consoleSink())
+		out.console = true
+		outputs = append(outputs, out)
+	}
+
+	var opened []*output
+	for i, sc := range config.Sinks {
+		sc.OTLP = sc.OTLP.withService(config.ServiceName, config.ServiceVersion)
+		sink, err := sc.open()
+		if err != nil {
+			closeOutputs(opened, nil)
+			return nil, fmt.Errorf("failed to open sink %s: %w", sc.name(i), err)
+		}
+
+		colors := (sc.Type == "stdout" && isTerminal(os.Stdout)) || (sc.Type == "stderr" && isTerminal(os.Stderr))
+		formatter := config.formatter(sc.Format, colors && sc.Sink == nil)
+		out := newOutput(sc.name(i), sc.Level, formatter, sink)
+		if size := sc.queueSize(); size > 0 {
+			out.startQueue(size)
+		}
+		outputs = append(outputs, out)
+		if sc.Sink == nil {
+			opened = append(opened, out)
+		}
+	}
+
+	return outputs, nil
+}
+
+// consoleSink returns the sink of the console output: the journal when the
+// process runs under systemd with its output connected to the journal, so
+// that levels and fields survive, and stdout otherwise
+func consoleSink() Sink {
+	if JournaldDetected() {
+		if sink, err := NewJournaldSink(JournaldConfig{}); err == nil {
+			return sink
+		}
+	}
+	return NewWriterSink(os.Stdout)
+}
+
+// closeOutputs drains the queues of outputs and closes their sinks that are
+// not used by keep
+func closeOutputs(outputs, keep []*output) error {
+	var errs []error
+	for _, out := range outputs {
+		out.drain()
+		if usesSink(keep, out.sink) {
+			continue
+		}
+		if err := out.sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", out.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// usesSink reports whether one of outputs writes to sink
+func usesSink(outputs []*output, sink Sink) bool {
+	for _, out := range outputs {
+		if sameSink(out.sink, sink) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSink compares sinks by identity without panicking on sink types that
+// are not comparable
+func sameSink(a, b Sink) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// sinkStats returns the statistics of every output keyed by name
+func sinkStats(outputs []*output) map[string]interface{} {
+	stats := make(map[string]interface{}, len(outputs))
+	for _, out := range outputs {
+		stats[out.name] = out.stats()
+	}
+	return stats
+}
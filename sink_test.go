@@ -0,0 +1,276 @@
+package panlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// failingSink is a sink that always fails or panics
+type failingSink struct {
+	panics bool
+	closed bool
+}
+
+func (s *failingSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	if s.panics {
+		panic("broken sink")
+	}
+	return errors.New("collector unreachable")
+}
+
+func (s *failingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// recordingSink keeps the entries written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	entries []*logrus.Entry
+	lines   []string
+}
+
+func (s *recordingSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	s.lines = append(s.lines, string(p))
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+func TestSinksIsolateFailures(t *testing.T) {
+	var buf bytes.Buffer
+	broken := &failingSink{}
+	panicking := &failingSink{panics: true}
+
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel: "debug",
+		Sinks: []SinkConfig{
+			{Name: "broken", Sink: broken},
+			{Name: "panicking", Sink: panicking},
+			{Name: "buffer", Level: "info", Format: "json", Sink: NewWriterSink(&buf)},
+			{Name: "errors", Type: "file", Level: "error", File: LogRotatorConfig{FilePath: "testdata/sink_errors.log"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Debug("Debug message")
+	logger.Info("Info message")
+	logger.Error("Error message")
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("Expected 2 entries in the buffer sink, got %d: %s", lines, buf.String())
+	}
+
+	content, err := os.ReadFile("testdata/sink_errors.log")
+	if err != nil {
+		t.Fatalf("Failed to read file sink: %v", err)
+	}
+	if !strings.Contains(string(content), `msg="Error message"`) || strings.Contains(string(content), "Info message") {
+		t.Errorf("Unexpected file sink content: %s", content)
+	}
+
+	stats := logger.GetStats()["sinks"].(map[string]interface{})
+	if failed := stats["broken"].(map[string]interface{})["failed"]; failed != uint64(3) {
+		t.Errorf("Expected 3 failures for the broken sink, got %v", failed)
+	}
+	if lastErr := stats["panicking"].(map[string]interface{})["last_error"]; !strings.Contains(lastErr.(string), "panicked") {
+		t.Errorf("Expected panic to be recorded, got %v", lastErr)
+	}
+	if written := stats["buffer"].(map[string]interface{})["written"]; written != uint64(2) {
+		t.Errorf("Expected 2 writes to the buffer sink, got %v", written)
+	}
+	if _, ok := stats["errors"].(map[string]interface{})["file_path"]; !ok {
+		t.Error("Expected file sink stats to include the rotator stats")
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+	if !broken.closed || !panicking.closed {
+		t.Error("Expected custom sinks to be closed with the logger")
+	}
+}
+
+func TestSinkConfigValidation(t *testing.T) {
+	_, err := NewLogger(LoggerConfig{
+		LogFile: "testdata/sink_validation.log",
+		Sinks: []SinkConfig{
+			{Type: "file"},
+			{Type: "carrier-pigeon"},
+			{Name: "file", Type: "stdout"},
+			{Type: "stderr", Level: "loud"},
+		},
+	})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	for _, field := range []string{"Sinks[0].File.FilePath", "Sinks[1].Type", "Sinks[2].Name", "Sinks[3].Level"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+}
+
+func TestRegisterSinkType(t *testing.T) {
+	recorder := &recordingSink{}
+	RegisterSinkType("recorder", func(config SinkConfig) (Sink, error) {
+		if config.Options["tag"] != "test" {
+			return nil, errors.New("missing tag option")
+		}
+		return recorder, nil
+	})
+
+	path := filepath.Join("testdata", "sinks.yaml")
+	content := `log_level: info
+sinks:
+  - type: recorder
+    format: json
+    options:
+      tag: test
+  - name: audit
+    type: file
+    path: testdata/sinks_audit.log
+    max_size: 1MB
+    max_backups: none
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Sinks[1].File.MaxBackups != NoBackups {
+		t.Errorf("Expected flattened file settings, got %+v", config.Sinks[1].File)
+	}
+
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WithField("user", "jane").Info("Registered sink message")
+
+	if len(recorder.lines) != 1 || !strings.Contains(recorder.lines[0], `"user":"jane"`) {
+		t.Errorf("Unexpected recorded lines: %q", recorder.lines)
+	}
+	if recorder.entries[0].Level != logrus.InfoLevel {
+		t.Errorf("Expected the entry to be passed to the sink, got level %v", recorder.entries[0].Level)
+	}
+
+	if err := logger.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	if _, err := os.Stat("testdata/sinks_audit.log"); err != nil {
+		t.Errorf("Expected the file sink to be reopened after rotation: %v", err)
+	}
+}
+
+// blockingSink blocks every write until it is released
+type blockingSink struct {
+	recordingSink
+	release chan struct{}
+}
+
+func (s *blockingSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	<-s.release
+	return s.recordingSink.WriteEntry(entry, p)
+}
+
+func TestSinkQueue(t *testing.T) {
+	var buf bytes.Buffer
+	slow := &blockingSink{release: make(chan struct{})}
+
+	logger, err := NewLogger(LoggerConfig{
+		Format: "json",
+		Sinks: []SinkConfig{
+			{Name: "slow", Sink: slow, QueueSize: 2},
+			{Name: "buffer", Sink: NewWriterSink(&buf)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	// The first entry blocks the queue's goroutine, the next two fill the
+	// queue and the last one is dropped, without holding up the buffer
+	logger.WithField("i", 0).Info("Queued message")
+	for start := time.Now(); len(logger.router.current()[0].queue) > 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Timed out waiting for the queue")
+		}
+	}
+	for i := 1; i < 4; i++ {
+		logger.WithField("i", i).Info("Queued message")
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("Expected 4 entries in the buffer sink, got %d", lines)
+	}
+
+	stats := logger.GetStats()["sinks"].(map[string]interface{})["slow"].(map[string]interface{})
+	if stats["queue_dropped"] != uint64(1) {
+		t.Errorf("Expected 1 dropped entry, got %v", stats)
+	}
+
+	close(slow.release)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+	if len(slow.entries) != 3 {
+		t.Fatalf("Expected queued entries to be delivered on close, got %d", len(slow.entries))
+	}
+	for i, entry := range slow.entries {
+		if entry.Data["i"] != i || entry.Message != "Queued message" {
+			t.Errorf("Unexpected queued entry %d: %v %s", i, entry.Data, entry.Message)
+		}
+	}
+}
+
+func TestSinkQueueFatalTimeout(t *testing.T) {
+	slow := &blockingSink{release: make(chan struct{})}
+	out := newOutput("slow", "", &logrus.JSONFormatter{}, slow)
+	out.startQueue(1)
+	out.flushTimeout = 50 * time.Millisecond
+
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.FatalLevel
+
+	// The first entry blocks the sink, the second waits in the queue and
+	// the third finds the queue full; none of them may block for long
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		out.write(entry, []byte("fatal\n"))
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected fatal entries to give up after the flush timeout, took %s", elapsed)
+	}
+	if stats := out.stats(); stats["queue_dropped"] != uint64(1) || stats["failed"] != uint64(3) {
+		t.Errorf("Expected 1 dropped and 3 failed entries, got %v", stats)
+	}
+
+	close(slow.release)
+	out.drain()
+	if len(slow.entries) != 2 {
+		t.Errorf("Expected the 2 queued entries to be delivered, got %d", len(slow.entries))
+	}
+}
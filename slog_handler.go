@@ -0,0 +1,133 @@
+package panlog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SlogHandler is a slog.Handler writing through a Logger, so that slog and
+// logrus entries share the logger's outputs, formatters, levels and hooks.
+// Attributes become entry fields; attributes in groups are named
+// "group.key". Entries also carry the fields of the logger's context
+// extractors.
+type SlogHandler struct {
+	entry  *logrus.Entry
+	parent *Logger
+	fields logrus.Fields
+	prefix string // Group prefix of attributes added from now on
+}
+
+// SlogHandler returns a slog.Handler writing through the logger
+func (l *Logger) SlogHandler() *SlogHandler {
+	return &SlogHandler{entry: logrus.NewEntry(&l.Logger), parent: l}
+}
+
+// SlogHandler returns a slog.Handler writing through the module, with the
+// module's level and field
+func (m *ModuleLogger) SlogHandler() *SlogHandler {
+	return &SlogHandler{entry: m.Entry, parent: m.parent}
+}
+
+// Slog returns a slog.Logger writing through the logger
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(l.SlogHandler())
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.entry.Logger.IsLevelEnabled(logrusLevel(level))
+}
+
+// Handle implements slog.Handler
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make(logrus.Fields, len(h.fields)+record.NumAttrs())
+	if ctx != nil {
+		for key, value := range h.parent.contextFields(ctx) {
+			fields[key] = value
+		}
+	}
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, attr)
+		return true
+	})
+
+	entry := h.entry.WithFields(fields).WithTime(record.Time)
+	if ctx != nil {
+		entry = entry.WithContext(ctx)
+	}
+	entry.Log(logrusLevel(record.Level), record.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	fields := make(logrus.Fields, len(h.fields)+len(attrs))
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+	for _, attr := range attrs {
+		addSlogAttr(fields, h.prefix, attr)
+	}
+
+	clone := *h
+	clone.fields = fields
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// addSlogAttr adds an attribute to fields, flattening groups
+func addSlogAttr(fields logrus.Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			addSlogAttr(fields, groupPrefix, member)
+		}
+		return
+	}
+
+	fields[prefix+attr.Key] = value.Any()
+}
+
+// logrusLevel maps a slog level to the nearest logrus level. Levels above
+// error map to error so that slog never exits or panics.
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return logrus.TraceLevel
+	case level < slog.LevelInfo:
+		return logrus.DebugLevel
+	case level < slog.LevelWarn:
+		return logrus.InfoLevel
+	case level < slog.LevelError:
+		return logrus.WarnLevel
+	default:
+		return logrus.ErrorLevel
+	}
+}
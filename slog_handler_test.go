@@ -0,0 +1,95 @@
+package panlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSlogHandler(t *testing.T) {
+	logFile := filepath.Join("testdata", "slog.log")
+	os.Remove(logFile)
+
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel:     "info",
+		LogFile:      logFile,
+		Format:       "json",
+		ModuleLevels: map[string]string{"db": "debug"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	slogger := logger.Slog().With("service", "api").WithGroup("req")
+	slogger.Info("Slog message", "id", 7, slog.Group("user", "name", "bob"), "err", errors.New("boom"))
+	slogger.Debug("Filtered by the logger level")
+	logger.Info("Logrus message")
+
+	dbLogger := slog.New(logger.Module("db").SlogHandler())
+	dbLogger.DebugContext(ContextWithRequestID(context.Background(), "req-1"), "Module debug")
+	logger.Close()
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 entries, got %d: %s", len(lines), data)
+	}
+
+	entries := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal(line, &entries[i]); err != nil {
+			t.Fatalf("Failed to parse entry %q: %v", line, err)
+		}
+	}
+
+	expected := map[string]interface{}{
+		"msg":           "Slog message",
+		"level":         "info",
+		"service":       "api",
+		"req.id":        float64(7),
+		"req.user.name": "bob",
+		"req.err":       "boom",
+	}
+	for key, value := range expected {
+		if entries[0][key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, entries[0][key])
+		}
+	}
+	if entries[1]["msg"] != "Logrus message" {
+		t.Errorf("Expected logrus entry in the same file, got %v", entries[1])
+	}
+	if entries[2]["msg"] != "Module debug" || entries[2][ModuleField] != "db" || entries[2][RequestIDField] != "req-1" {
+		t.Errorf("Expected module debug entry with context fields, got %v", entries[2])
+	}
+
+	if counts := logger.Counters().Levels; counts[logrus.InfoLevel] != 2 {
+		t.Errorf("Expected slog entries to be counted, got %v", counts)
+	}
+}
+
+func TestLogrusLevel(t *testing.T) {
+	cases := map[slog.Level]logrus.Level{
+		slog.LevelDebug - 4: logrus.TraceLevel,
+		slog.LevelDebug:     logrus.DebugLevel,
+		slog.LevelInfo:      logrus.InfoLevel,
+		slog.LevelInfo + 2:  logrus.InfoLevel,
+		slog.LevelWarn:      logrus.WarnLevel,
+		slog.LevelError:     logrus.ErrorLevel,
+		slog.LevelError + 4: logrus.ErrorLevel,
+	}
+	for level, expected := range cases {
+		if got := logrusLevel(level); got != expected {
+			t.Errorf("Expected %v for %v, got %v", expected, level, got)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package panlog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SyslogFacility is a syslog facility code
+type SyslogFacility int
+
+// Syslog facilities as defined by RFC 5424
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// facilityNames maps facility keywords to facilities
+var facilityNames = map[string]SyslogFacility{
+	"kern": FacilityKern, "user": FacilityUser, "mail": FacilityMail,
+	"daemon": FacilityDaemon, "auth": FacilityAuth, "syslog": FacilitySyslog,
+	"lpr": FacilityLPR, "news": FacilityNews, "uucp": FacilityUUCP,
+	"cron": FacilityCron, "authpriv": FacilityAuthPriv, "ftp": FacilityFTP,
+	"local0": FacilityLocal0, "local1": FacilityLocal1, "local2": FacilityLocal2,
+	"local3": FacilityLocal3, "local4": FacilityLocal4, "local5": FacilityLocal5,
+	"local6": FacilityLocal6, "local7": FacilityLocal7,
+}
+
+// ParseSyslogFacility parses a facility keyword such as "daemon" or "local0"
+func ParseSyslogFacility(name string) (SyslogFacility, error) {
+	facility, ok := facilityNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return facility, nil
+}
+
+// Syslog message formats
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+)
+
+// SyslogConfig configures a syslog sink
+type SyslogConfig struct {
+	Network  string         // unixgram, unix, udp or tcp (defaults to the local syslog socket)
+	Address  string         // Socket path or host:port (defaults to the local syslog socket)
+	Format   string         // SyslogRFC5424 (default) or SyslogRFC3164
+	Facility SyslogFacility // Facility of every message (defaults to FacilityUser)
+	AppName  string         // APP-NAME or TAG (defaults to the program name)
+	ProcID   string         // PROCID (defaults to the process ID)
+	Hostname string         // HOSTNAME (defaults to os.Hostname)
+
+	// SD-ID of the structured data element carrying the entry fields, e.g.
+	// "fields@32473". When set, the message is the entry's message and its
+	// fields travel as structured data (RFC 5424 only); otherwise the message
+	// is the entry rendered by the sink's formatter.
+	StructuredDataID string
+
+	Timeout           time.Duration // Dial and write timeout (defaults to 5s)
+	ReconnectInterval time.Duration // Minimum time between reconnect attempts (defaults to 1s)
+}
+
+// localSyslogSockets are probed when no address is configured
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogSink writes entries to a syslog daemon. Stream connections use
+// octet-counting framing (RFC 6587); datagram connections send one message
+// per datagram.
+type SyslogSink struct {
+	config SyslogConfig
+
+	mu         sync.Mutex
+	conn       net.Conn
+	lastDial   time.Time
+	reconnects uint64
+}
+
+// NewSyslogSink connects to the syslog daemon described by config
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	if config.Format == "" {
+		config.Format = SyslogRFC5424
+	}
+	if config.Format != SyslogRFC5424 && config.Format != SyslogRFC3164 {
+		return nil, fmt.Errorf("unknown syslog format %q", config.Format)
+	}
+	if config.Facility < FacilityKern || config.Facility > FacilityLocal7 {
+		return nil, fmt.Errorf("invalid syslog facility %d", config.Facility)
+	}
+	switch config.Network {
+	case "", "unixgram", "unix":
+	case "udp", "tcp":
+		if config.Address == "" {
+			return nil, fmt.Errorf("syslog address is required for %s", config.Network)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", config.Network)
+	}
+	if config.Facility == FacilityKern {
+		// Applications cannot log as the kernel, so the zero value means user
+		config.Facility = FacilityUser
+	}
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+	if config.ProcID == "" {
+		config.ProcID = strconv.Itoa(os.Getpid())
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.ReconnectInterval == 0 {
+		config.ReconnectInterval = time.Second
+	}
+
+	s := &SyslogSink{config: config}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// WriteEntry implements Sink
+func (s *SyslogSink) WriteEntry(entry *logrus.Entry, p []byte) error {
+	msg := s.message(entry, p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Retry once on a fresh connection if the daemon went away
+	err := s.send(msg)
+	if err == nil {
+		return nil
+	}
+	s.disconnect()
+	if time.Since(s.lastDial) < s.config.ReconnectInterval {
+		return err
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	s.reconnects++
+	return s.send(msg)
+}
+
+// Close implements Sink
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.disconnect()
+}
+
+// GetStats returns statistics about the syslog connection
+func (s *SyslogSink) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"network":    s.config.Network,
+		"address":    s.config.Address,
+		"connected":  s.conn != nil,
+		"reconnects": s.reconnects,
+	}
+}
+
+// connect dials the daemon; the caller must hold s.mu unless the sink is
+// not shared yet
+func (s *SyslogSink) connect() error {
+	s.lastDial = time.Now()
+
+	if s.config.Network != "" && s.config.Address != "" {
+		conn, err := net.DialTimeout(s.config.Network, s.config.Address, s.config.Timeout)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		s.conn = conn
+		return nil
+	}
+
+	// Probe the local sockets like the standard library's log/syslog
+	addresses := localSyslogSockets
+	if s.config.Address != "" {
+		addresses = []string{s.config.Address}
+	}
+	networks := []string{"unixgram", "unix"}
+	if s.config.Network != "" {
+		networks = []string{s.config.Network}
+	}
+
+	var lastErr error
+	for _, address := range addresses {
+		for _, network := range networks {
+			conn, err := net.DialTimeout(network, address, s.config.Timeout)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			s.config.Network, s.config.Address = network, address
+			s.conn = conn
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to connect to local syslog: %w", lastErr)
+}
+
+// disconnect closes the connection; the caller must hold s.mu
+func (s *SyslogSink) disconnect() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// send writes a message with the framing of the connection; the caller must
+// hold s.mu
+func (s *SyslogSink) send(msg []byte) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to syslog")
+	}
+
+	if s.config.Network == "tcp" || s.config.Network == "unix" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// message renders an entry as a syslog message
+func (s *SyslogSink) message(entry *logrus.Entry, p []byte) []byte {
+	msg := bytes.TrimRight(p, "\n")
+	if s.config.StructuredDataID != "" {
+		msg = []byte(entry.Message)
+	}
+
+	pri := int(s.config.Facility)*8 + syslogSeverity(entry.Level)
+
+	var buf bytes.Buffer
+	if s.config.Format == SyslogRFC3164 {
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%s]: ", pri, entry.Time.Format(time.Stamp),
+			s.config.Hostname, s.config.AppName, s.config.ProcID)
+		buf.Write(msg)
+		return buf.Bytes()
+	}
+
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - ", pri, entry.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(s.config.Hostname, 255), syslogHeaderField(s.config.AppName, 48),
+		syslogHeaderField(s.config.ProcID, 128))
+	s.writeStructuredData(&buf, entry.Data)
+	buf.WriteByte(' ')
+	buf.Write(msg)
+	return buf.Bytes()
+}
+
+// writeStructuredData renders the entry fields as an RFC 5424 SD-ELEMENT
+func (s *SyslogSink) writeStructuredData(buf *bytes.Buffer, data logrus.Fields) {
+	if s.config.StructuredDataID == "" || len(data) == 0 {
+		buf.WriteByte('-')
+		return
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('[')
+	buf.WriteString(sdName(s.config.StructuredDataID))
+	for _, key := range keys {
+		value := data[key]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(sdName(key))
+		buf.WriteString(`="`)
+		buf.WriteString(sdParamEscaper.Replace(fmt.Sprint(value)))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte(']')
+}
+
+// sdParamEscaper escapes the characters RFC 5424 reserves in PARAM-VALUE
+var sdParamEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// sdName makes a string a valid SD-NAME: printable ASCII without '=', ' ',
+// ']' and '"', at most 32 characters
+func sdName(s string) string {
+	name := []byte(s)
+	for i, c := range name {
+		if c <= ' ' || c >= 127 || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if len(name) == 0 {
+		return "_"
+	}
+	return string(name)
+}
+
+// syslogHeaderField makes a string a valid RFC 5424 header field
+func syslogHeaderField(s string, maxLen int) string {
+	field := []byte(s)
+	for i, c := range field {
+		if c <= ' ' || c >= 127 {
+			field[i] = '_'
+		}
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
+
+// syslogSeverity maps a logrus level to a syslog severity
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 0 // Emergency
+	case logrus.FatalLevel:
+		return 2 // Critical
+	case logrus.ErrorLevel:
+		return 3 // Error
+	case logrus.WarnLevel:
+		return 4 // Warning
+	case logrus.InfoLevel:
+		return 6 // Informational
+	default:
+		return 7 // Debug
+	}
+}
+
+// syslogConfigFromOptions applies string options from config files
+func syslogConfigFromOptions(config SyslogConfig, options map[string]string) (SyslogConfig, error) {
+	for key, value := range options {
+		var err error
+		switch key {
+		case "network":
+			config.Network = value
+		case "address":
+			config.Address = value
+		case "format":
+			config.Format = value
+		case "facility":
+			config.Facility, err = ParseSyslogFacility(value)
+		case "app_name":
+			config.AppName = value
+		case "proc_id":
+			config.ProcID = value
+		case "hostname":
+			config.Hostname = value
+		case "structured_data_id":
+			config.StructuredDataID = value
+		case "timeout":
+			config.Timeout, err = ParseDuration(value)
+		case "reconnect_interval":
+			config.ReconnectInterval, err = ParseDuration(value)
+		default:
+			err = fmt.Errorf("unknown option")
+		}
+		if err != nil {
+			return config, fmt.Errorf("syslog option %s: %w", key, err)
+		}
+	}
+	return config, nil
+}
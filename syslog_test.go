@@ -0,0 +1,222 @@
+package panlog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeSyslog is an in-process syslog listener collecting received messages
+type fakeSyslog struct {
+	messages chan string
+	addr     string
+	close    func()
+}
+
+// newFakeSyslogPacket listens for datagrams on network ("udp" or "unixgram")
+func newFakeSyslogPacket(t *testing.T, network, address string) *fakeSyslog {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", network, err)
+	}
+
+	f := &fakeSyslog{messages: make(chan string, 16), addr: conn.LocalAddr().String(), close: func() { conn.Close() }}
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			f.messages <- string(buf[:n])
+		}
+	}()
+	return f
+}
+
+// newFakeSyslogTCP accepts TCP connections and decodes octet-counted frames.
+// Every connection is closed after maxPerConn messages.
+func newFakeSyslogTCP(t *testing.T, maxPerConn int) *fakeSyslog {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on tcp: %v", err)
+	}
+
+	f := &fakeSyslog{messages: make(chan string, 16), addr: ln.Addr().String(), close: func() { ln.Close() }}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for i := 0; i < maxPerConn; i++ {
+					length, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(length))
+					frame := make([]byte, n)
+					if _, err := io.ReadFull(r, frame); err != nil {
+						return
+					}
+					f.messages <- string(frame)
+				}
+			}()
+		}
+	}()
+	return f
+}
+
+// receive waits for the next message
+func (f *fakeSyslog) receive(t *testing.T) string {
+	select {
+	case msg := <-f.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a syslog message")
+		return ""
+	}
+}
+
+func TestSyslogSinkRFC5424(t *testing.T) {
+	server := newFakeSyslogPacket(t, "udp", "127.0.0.1:0")
+	defer server.close()
+
+	logger, err := NewLogger(LoggerConfig{
+		LogLevel: "debug",
+		Sinks: []SinkConfig{{
+			Type: "syslog",
+			Syslog: SyslogConfig{
+				Network:          "udp",
+				Address:          server.addr,
+				Facility:         FacilityLocal0,
+				AppName:          "panlog-test",
+				ProcID:           "42",
+				Hostname:         "testhost",
+				StructuredDataID: "fields@32473",
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.WithFields(logrus.Fields{"user": `ja"ne`, "path": "/a]b"}).Warn("Login failed")
+
+	msg := server.receive(t)
+	// local0 (16) * 8 + warning (4) = 132
+	if !strings.HasPrefix(msg, "<132>1 ") {
+		t.Errorf("Unexpected priority/version: %q", msg)
+	}
+	if !strings.Contains(msg, " testhost panlog-test 42 - ") {
+		t.Errorf("Unexpected header: %q", msg)
+	}
+	if !strings.HasSuffix(msg, `[fields@32473 path="/a\]b" user="ja\"ne"] Login failed`) {
+		t.Errorf("Unexpected structured data or message: %q", msg)
+	}
+}
+
+func TestSyslogSinkRFC3164Unixgram(t *testing.T) {
+	socket := filepath.Join(os.TempDir(), "panlog-syslog-"+strconv.Itoa(os.Getpid())+".sock")
+	os.Remove(socket)
+	server := newFakeSyslogPacket(t, "unixgram", socket)
+	defer os.Remove(socket)
+	defer server.close()
+
+	sink, err := NewSyslogSink(SyslogConfig{
+		Address:  socket,
+		Format:   SyslogRFC3164,
+		Facility: FacilityDaemon,
+		AppName:  "app",
+		ProcID:   "7",
+		Hostname: "host",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create syslog sink: %v", err)
+	}
+	defer sink.Close()
+
+	entry := &logrus.Entry{Level: logrus.ErrorLevel, Time: time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC), Message: "boom"}
+	if err := sink.WriteEntry(entry, []byte("level=error msg=boom\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	// daemon (3) * 8 + error (3) = 27
+	if msg := server.receive(t); msg != "<27>Mar  5 14:07:09 host app[7]: level=error msg=boom" {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+}
+
+func TestSyslogSinkTCPReconnect(t *testing.T) {
+	server := newFakeSyslogTCP(t, 1)
+	defer server.close()
+
+	sink, err := NewSyslogSink(SyslogConfig{
+		Network:           "tcp",
+		Address:           server.addr,
+		ReconnectInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create syslog sink: %v", err)
+	}
+	defer sink.Close()
+
+	entry := &logrus.Entry{Level: logrus.InfoLevel, Time: time.Now(), Message: "first"}
+	if err := sink.WriteEntry(entry, []byte("first\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if msg := server.receive(t); !strings.HasPrefix(msg, "<14>1 ") || !strings.HasSuffix(msg, " - - first") {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+
+	// The server dropped the connection; keep writing until the sink notices
+	// and reconnects
+	deadline := time.Now().Add(2 * time.Second)
+	for sink.GetStats()["reconnects"] == uint64(0) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the sink to reconnect")
+		}
+		entry.Message = "retry"
+		sink.WriteEntry(entry, []byte("retry\n"))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if msg := server.receive(t); !strings.HasSuffix(msg, "retry") {
+		t.Errorf("Unexpected message after reconnect: %q", msg)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	severities := map[logrus.Level]int{
+		logrus.PanicLevel: 0,
+		logrus.FatalLevel: 2,
+		logrus.ErrorLevel: 3,
+		logrus.WarnLevel:  4,
+		logrus.InfoLevel:  6,
+		logrus.DebugLevel: 7,
+		logrus.TraceLevel: 7,
+	}
+	for level, want := range severities {
+		if got := syslogSeverity(level); got != want {
+			t.Errorf("syslogSeverity(%v) = %d, want %d", level, got, want)
+		}
+	}
+
+	if _, err := NewSyslogSink(SyslogConfig{Network: "udp"}); err == nil {
+		t.Error("Expected an error for udp without an address")
+	}
+	if _, err := ParseSyslogFacility("local9"); err == nil {
+		t.Error("Expected an error for an unknown facility")
+	}
+}
@@ -0,0 +1,215 @@
+package panlog
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Retention limits and retry counts accept zero for "use the default", a
+// positive value, or one of the following sentinels. NoBackups and NoRetries
+// share a value as each applies to a single field.
+const (
+	// Unlimited disables a retention limit: MaxSize never triggers rotation,
+	// MaxAge never expires backups and MaxBackups keeps every backup. As
+	// HTTPConfig.MaxRetries, it retries until the request succeeds.
+	Unlimited = -1
+
+	// NoBackups makes MaxBackups discard rotated files instead of keeping them
+	NoBackups = -2
+
+	// NoRetries makes HTTPConfig.MaxRetries give up on the first failure
+	NoRetries = -2
+)
+
+// FieldError describes a single invalid configuration field
+type FieldError struct {
+	Field  string      // Name of the offending field
+	Value  interface{} // Value that was rejected
+	Reason string      // Why the value was rejected
+}
+
+// Error implements the error interface
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s (got %v)", e.Field, e.Reason, e.Value)
+}
+
+// ValidationError aggregates every FieldError found in a configuration
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual field errors for errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// validator collects field errors
+type validator struct {
+	errs []*FieldError
+}
+
+// add records an invalid field
+func (v *validator) add(field string, value interface{}, reason string) {
+	v.errs = append(v.errs, &FieldError{Field: field, Value: value, Reason: reason})
+}
+
+// err returns the collected errors, or nil if there are none
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// nested adds the field errors of a nested configuration under prefix
+func (v *validator) nested(prefix string, err error) {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		if err != nil {
+			v.add(prefix, nil, err.Error())
+		}
+		return
+	}
+	for _, fe := range verr.Errors {
+		v.add(prefix+"."+fe.Field, fe.Value, fe.Reason)
+	}
+}
+
+// level checks an optional logrus level name
+func (v *validator) level(field, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := logrus.ParseLevel(value); err != nil {
+		v.add(field, value, "unknown log level")
+	}
+}
+
+// format checks an optional formatter name
+func (v *validator) format(field, value string) {
+	if value == "" {
+		return
+	}
+	for _, name := range formats {
+		if value == name {
+			return
+		}
+	}
+	v.add(field, value, fmt.Sprintf("unknown format (want one of %s)", strings.Join(formats, ", ")))
+}
+
+// retention checks the MaxSize, MaxAge and MaxBackups triple shared by
+// LoggerConfig and LogRotatorConfig
+func (v *validator) retention(maxSize int64, maxAge time.Duration, maxBackups int, compress bool) {
+	if maxSize < 0 && maxSize != Unlimited {
+		v.add("MaxSize", maxSize, "must be positive, zero for the default or Unlimited")
+	}
+	if maxAge < 0 && maxAge != Unlimited {
+		v.add("MaxAge", maxAge, "must be positive, zero for the default or Unlimited")
+	}
+	if maxBackups < 0 && maxBackups != Unlimited && maxBackups != NoBackups {
+		v.add("MaxBackups", maxBackups, "must be positive, zero for the default, Unlimited or NoBackups")
+	}
+	if maxBackups == NoBackups && compress {
+		v.add("Compress", compress, "has no effect when MaxBackups is NoBackups")
+	}
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every invalid field, or nil if the configuration is usable
+func (c LoggerConfig) Validate() error {
+	var v validator
+
+	v.level("LogLevel", c.LogLevel)
+	v.level("FileLevel", c.FileLevel)
+	v.level("ConsoleLevel", c.ConsoleLevel)
+	v.format("Format", c.Format)
+	if c.JSONFormat && c.Format != "" && c.Format != "json" {
+		v.add("JSONFormat", c.JSONFormat, "conflicts with Format")
+	}
+	v.format("FileFormat", c.FileFormat)
+	v.format("ConsoleFormat", c.ConsoleFormat)
+	v.retention(c.MaxSize, c.MaxAge, c.MaxBackups, c.Compress)
+
+	names := map[string]bool{"file": c.LogFile != "", "console": c.ConsoleOutput}
+	for i, sc := range c.Sinks {
+		field := fmt.Sprintf("Sinks[%d]", i)
+		if name := sc.name(i); names[name] {
+			v.add(field+".Name", name, "duplicate sink name")
+		} else {
+			names[name] = true
+		}
+		v.nested(field, sc.Validate())
+	}
+
+	if c.ErrorLog.Enabled {
+		rotatorConfig := c.errorLogRotatorConfig()
+		v.level("ErrorLog.Level", c.ErrorLog.Level)
+		v.nested("ErrorLog", rotatorConfig.Validate())
+		if rotatorConfig.FilePath != "" && rotatorConfig.FilePath == c.LogFile {
+			v.add("ErrorLog.FilePath", rotatorConfig.FilePath, "must differ from LogFile")
+		}
+	}
+
+	v.nested("Redaction", c.Redaction.Validate())
+	v.nested("Sampling", c.Sampling.Validate())
+	if c.DedupWindow < 0 {
+		v.add("DedupWindow", c.DedupWindow, "must not be negative")
+	}
+	for name, limit := range c.RateLimits {
+		field := fmt.Sprintf("RateLimits[%q]", name)
+		if _, err := logrus.ParseLevel(name); err != nil {
+			v.add(field, name, "unknown log level")
+		}
+		if limit.Rate <= 0 {
+			v.add(field+".Rate", limit.Rate, "must be positive")
+		}
+		if limit.Burst < 0 {
+			v.add(field+".Burst", limit.Burst, "must not be negative")
+		}
+	}
+
+	for pattern, level := range c.ModuleLevels {
+		field := fmt.Sprintf("ModuleLevels[%q]", pattern)
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			v.add(field, pattern, "invalid module pattern")
+		}
+		if level == "" {
+			v.add(field, level, "level is required")
+		}
+		v.level(field, level)
+	}
+
+	return v.err()
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every invalid field, or nil if the configuration is usable
+func (c LogRotatorConfig) Validate() error {
+	var v validator
+
+	if c.FilePath == "" {
+		v.add("FilePath", c.FilePath, "file path is required")
+	}
+	v.retention(c.MaxSize, c.MaxAge, c.MaxBackups, c.Compress)
+
+	return v.err()
+}
@@ -0,0 +1,112 @@
+package panlog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ConfigWatcher reloads a logger's configuration when its config file changes
+type ConfigWatcher struct {
+	logger   *Logger
+	sources  ConfigSources
+	interval time.Duration
+
+	modTime time.Time
+	size    int64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// WatchConfig polls sources.File every interval and applies the configuration
+// loaded from sources with Reconfigure whenever the file changes. Reload
+// failures are logged through the logger and keep the current configuration.
+func (l *Logger) WatchConfig(sources ConfigSources, interval time.Duration) (*ConfigWatcher, error) {
+	if sources.File == "" {
+		return nil, fmt.Errorf("config file is required")
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	stat, err := os.Stat(sources.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat config file: %w", err)
+	}
+
+	w := &ConfigWatcher{
+		logger:   l,
+		sources:  sources,
+		interval: interval,
+		modTime:  stat.ModTime(),
+		size:     stat.Size(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Close stops watching the config file
+func (w *ConfigWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
+
+// run polls the config file until the watcher is closed
+func (w *ConfigWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if w.changed() {
+				w.reload()
+			}
+		}
+	}
+}
+
+// changed reports whether the config file was modified since the last check
+func (w *ConfigWatcher) changed() bool {
+	stat, err := os.Stat(w.sources.File)
+	if err != nil {
+		// The file may be replaced by an editor; try again next tick
+		return false
+	}
+
+	if stat.ModTime().Equal(w.modTime) && stat.Size() == w.size {
+		return false
+	}
+
+	w.modTime = stat.ModTime()
+	w.size = stat.Size()
+	return true
+}
+
+// reload loads and applies the configuration
+func (w *ConfigWatcher) reload() {
+	config, err := w.sources.Load()
+	if err == nil {
+		err = w.logger.Reconfigure(config)
+	}
+
+	if err != nil {
+		w.logger.WithError(err).WithField("config_file", w.sources.File).Error("Failed to reload logger configuration")
+		return
+	}
+
+	w.logger.WithField("config_file", w.sources.File).Info("Reloaded logger configuration")
+}